@@ -0,0 +1,166 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// recordPostedPoll tracks a non-anonymous poll the bot just posted, so votes on it are stored
+func recordPostedPoll(sent tgbotapi.Message, date time.Time, scheduleID int64) {
+	if sent.Poll == nil || sent.Poll.IsAnonymous {
+		return
+	}
+
+	options := make([]string, len(sent.Poll.Options))
+	for i, opt := range sent.Poll.Options {
+		options[i] = opt.Text
+	}
+	if err := savePoll(sent.Poll.ID, sent.Chat.ID, sent.MessageID, date, sent.Poll.Question, options, scheduleID); err != nil {
+		LogError("Failed to save poll %s in chat %d: %v", sent.Poll.ID, sent.Chat.ID, err)
+	}
+}
+
+// handlePollAnswer stores a vote on a poll the bot posted and saves the voter as a member
+func handlePollAnswer(answer *tgbotapi.PollAnswer, ulog updateLog) {
+	chatID, err := findPollChatID(answer.PollID)
+	if err == sql.ErrNoRows {
+		return // Not a poll posted by the bot
+	}
+	if err != nil {
+		ulog.Error("Failed to look up poll %s: %v", answer.PollID, err)
+		return
+	}
+	if !answer.User.IsBot {
+		saveUser(chatID, &answer.User)
+	}
+
+	if err := saveVote(answer.PollID, answer.User.ID, answer.OptionIDs); err != nil {
+		ulog.Error("Failed to save vote of user %d on poll %s: %v", answer.User.ID, answer.PollID, err)
+		return
+	}
+	ulog.Info("User %d voted %v on poll %s", answer.User.ID, answer.OptionIDs, answer.PollID)
+}
+
+// handleAttendanceCommand shows who answered today's poll, or with "week" or "month"
+// a per-member attendance summary
+func handleAttendanceCommand(update tgbotapi.Update, ulog updateLog) {
+	chatID := update.Message.Chat.ID
+	lang := messageLanguage(update.Message)
+	now := time.Now().In(chatLocation(chatID))
+
+	switch strings.ToLower(strings.TrimSpace(update.Message.CommandArguments())) {
+	case "":
+		sendTodayAttendance(chatID, lang, now, ulog)
+	case "week":
+		sendAttendanceSummary(chatID, lang, tr(lang, "attendance.week"), now.AddDate(0, 0, -6), ulog)
+	case "month":
+		sendAttendanceSummary(chatID, lang, tr(lang, "attendance.month"), now.AddDate(0, 0, -29), ulog)
+	default:
+		sendText(chatID, tr(lang, "attendance.usage"))
+	}
+}
+
+func sendTodayAttendance(chatID int64, lang string, today time.Time, ulog updateLog) {
+	poll, err := findLatestPoll(chatID, today)
+	if err == sql.ErrNoRows {
+		sendText(chatID, tr(lang, "attendance.no_poll"))
+		return
+	}
+	if err != nil {
+		ulog.Error("Failed to find today's poll in chat %d: %v", chatID, err)
+		sendText(chatID, tr(lang, "attendance.poll_failed"))
+		return
+	}
+
+	voters, err := listPollVoters(poll.PollID)
+	if err != nil {
+		ulog.Error("Failed to list voters of poll %s: %v", poll.PollID, err)
+		sendText(chatID, tr(lang, "attendance.votes_failed"))
+		return
+	}
+
+	byOption := make([][]string, len(poll.Options))
+	for _, voter := range voters {
+		name := displayName(&voter.User)
+		if name == "" {
+			name = tr(lang, "attendance.user", voter.User.ID)
+		}
+		for _, optionID := range voter.OptionIDs {
+			if int(optionID) < len(byOption) {
+				byOption[optionID] = append(byOption[optionID], name)
+			}
+		}
+	}
+
+	lines := []string{tr(lang, "attendance.votes", poll.Question, len(voters))}
+	for i, option := range poll.Options {
+		names := "-"
+		if len(byOption[i]) > 0 {
+			names = strings.Join(byOption[i], ", ")
+		}
+		lines = append(lines, tr(lang, "attendance.option", option, len(byOption[i]), names))
+	}
+	sendText(chatID, strings.Join(lines, "\n"))
+}
+
+func sendAttendanceSummary(chatID int64, lang, period string, since time.Time, ulog updateLog) {
+	summary, total, err := getAttendanceSummary(chatID, since)
+	if err != nil {
+		ulog.Error("Failed to load attendance summary for chat %d: %v", chatID, err)
+		sendText(chatID, tr(lang, "attendance.summary_failed"))
+		return
+	}
+	if total == 0 {
+		sendText(chatID, tr(lang, "attendance.no_polls", period))
+		return
+	}
+
+	lines := []string{tr(lang, "attendance.summary", period, total)}
+	for _, row := range summary {
+		name := displayName(&row.User)
+		if name == "" {
+			name = tr(lang, "attendance.user", row.User.ID)
+		}
+		lines = append(lines, fmt.Sprintf("• %s: %d / %d / %d", name, row.Yes, row.Other, total-row.Answered))
+	}
+	sendText(chatID, strings.Join(lines, "\n"))
+}
+
+// remindNonVoters mentions the members who haven't answered the poll yet
+func remindNonVoters(poll trackedPoll, lang string) error {
+	mentions := getNonVoterMentions(poll.ChatID, poll.PollID)
+	if len(mentions) == 0 {
+		sendText(poll.ChatID, tr(lang, "reminder.everyone_answered", poll.Question))
+		return nil
+	}
+	return sendMentions(poll.ChatID, lang, tr(lang, "reminder.text", poll.Question), mentions)
+}
+
+// handleRemindCommand mentions the members who haven't answered today's poll
+func handleRemindCommand(update tgbotapi.Update, ulog updateLog) {
+	chatID := update.Message.Chat.ID
+	lang := messageLanguage(update.Message)
+
+	poll, err := findLatestPoll(chatID, time.Now().In(chatLocation(chatID)))
+	if err == sql.ErrNoRows {
+		sendText(chatID, tr(lang, "attendance.no_poll"))
+		return
+	}
+	if err != nil {
+		ulog.Error("Failed to find today's poll in chat %d: %v", chatID, err)
+		sendText(chatID, tr(lang, "attendance.poll_failed"))
+		return
+	}
+
+	if !canMentionAll(update.Message, ulog) || !checkMentionCooldown(update.Message, ulog) {
+		return
+	}
+	if err := remindNonVoters(poll, lang); err != nil {
+		ulog.Error("Failed to send reminder for poll %s in chat %d: %v", poll.PollID, chatID, err)
+	}
+	recordMentionSummon(update.Message)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"os"
+	"slices"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// purgeInterval is how often data of chats the bot left is checked for purging
+const purgeInterval = time.Hour
+
+// handleChatMigration moves a group's data to its new supergroup ID. Telegram announces the
+// upgrade in both chats, so either message triggers it and the second one is a no-op.
+func handleChatMigration(message *tgbotapi.Message, ulog updateLog) {
+	oldChatID, newChatID := message.Chat.ID, message.MigrateToChatID
+	if message.MigrateFromChatID != 0 {
+		oldChatID, newChatID = message.MigrateFromChatID, message.Chat.ID
+	}
+
+	ulog.Info("Chat %d was upgraded to supergroup %d", oldChatID, newChatID)
+	if err := migrateChatData(oldChatID, newChatID); err != nil {
+		ulog.Error("Failed to migrate chat %d to %d: %v", oldChatID, newChatID, err)
+		return
+	}
+	if err := setChatActive(newChatID, message.Chat.Title, true); err != nil {
+		ulog.Error("Failed to mark chat %d active: %v", newChatID, err)
+	}
+
+	if slices.Contains(specialChatIDs, oldChatID) {
+		ulog.Error("Special chat %d was upgraded to %d, update SPECIAL_CHAT_IDS", oldChatID, newChatID)
+	}
+}
+
+// startChatPurger removes the data of chats the bot left longer than CHAT_DATA_RETENTION ago.
+// Without the variable, data of inactive chats is kept.
+func startChatPurger(ctx context.Context) {
+	retentionStr := os.Getenv("CHAT_DATA_RETENTION")
+	if retentionStr == "" {
+		LogInfo("CHAT_DATA_RETENTION environment variable is not set, data of left chats is kept")
+		return
+	}
+	retention, err := parseDuration(retentionStr)
+	if err != nil || retention <= 0 {
+		LogError("Invalid CHAT_DATA_RETENTION: %s, data of left chats is kept", retentionStr)
+		return
+	}
+
+	backgroundTasks.Add(1)
+	go func() {
+		defer backgroundTasks.Done()
+		ticker := time.NewTicker(purgeInterval)
+		defer ticker.Stop()
+
+		for {
+			purged, err := purgeInactiveChats(time.Now().Add(-retention))
+			if err != nil {
+				LogError("Failed to purge inactive chats: %v", err)
+			} else if purged > 0 {
+				LogInfo("Purged data of %d chats left more than %s ago", purged, retention)
+			}
+
+			select {
+			case <-ctx.Done():
+				LogInfo("Chat purger stopped")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	LogInfo("Chat purger started with retention %s", retention)
+}
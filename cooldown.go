@@ -0,0 +1,170 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Default cooldowns used when a chat has not configured its own
+const (
+	defaultChatCooldown = time.Minute
+	defaultUserCooldown = 5 * time.Minute
+)
+
+// mentionSummon records who last triggered a mass mention and when
+type mentionSummon struct {
+	UserID   int64
+	UserName string
+	At       time.Time
+}
+
+// cooldownStore keeps track of recent mass mentions per chat and per user.
+// The in-memory implementation can be swapped for a persistent one later.
+type cooldownStore interface {
+	LastChatSummon(chatID int64) (mentionSummon, bool)
+	LastUserSummon(chatID, userID int64) (mentionSummon, bool)
+	RecordSummon(chatID int64, summon mentionSummon)
+}
+
+type chatUserKey struct {
+	chatID int64
+	userID int64
+}
+
+type memoryCooldownStore struct {
+	mu    sync.Mutex
+	chats map[int64]mentionSummon
+	users map[chatUserKey]mentionSummon
+}
+
+func newMemoryCooldownStore() *memoryCooldownStore {
+	return &memoryCooldownStore{
+		chats: make(map[int64]mentionSummon),
+		users: make(map[chatUserKey]mentionSummon),
+	}
+}
+
+func (s *memoryCooldownStore) LastChatSummon(chatID int64) (mentionSummon, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	summon, ok := s.chats[chatID]
+	return summon, ok
+}
+
+func (s *memoryCooldownStore) LastUserSummon(chatID, userID int64) (mentionSummon, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	summon, ok := s.users[chatUserKey{chatID, userID}]
+	return summon, ok
+}
+
+func (s *memoryCooldownStore) RecordSummon(chatID int64, summon mentionSummon) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chats[chatID] = summon
+	s.users[chatUserKey{chatID, summon.UserID}] = summon
+}
+
+// checkMentionCooldown reports whether the sender may trigger a mass mention now.
+// When throttled it replies with who summoned the pack last and when to try again.
+// Admins always bypass the cooldown.
+func checkMentionCooldown(message *tgbotapi.Message, ulog updateLog) bool {
+	chatID := message.Chat.ID
+	userID := message.From.ID
+
+	settings, err := getChatSettings(chatID)
+	if err != nil {
+		ulog.Error("Failed to load settings for chat %d: %v", chatID, err)
+		settings = defaultChatSettings()
+	}
+
+	now := time.Now()
+	var wait time.Duration
+	var last mentionSummon
+
+	if summon, ok := cooldowns.LastChatSummon(chatID); ok {
+		if remaining := summon.At.Add(settings.ChatCooldown).Sub(now); remaining > wait {
+			wait, last = remaining, summon
+		}
+	}
+	if summon, ok := cooldowns.LastUserSummon(chatID, userID); ok {
+		if remaining := summon.At.Add(settings.UserCooldown).Sub(now); remaining > wait {
+			wait, last = remaining, summon
+		}
+	}
+
+	if wait <= 0 {
+		return true
+	}
+	if isChatAdmin(chatID, userID, ulog) {
+		ulog.Info("Admin %d bypassed mention cooldown in chat %d", userID, chatID)
+		return true
+	}
+
+	ulog.Info("Throttled mass mention from user %d in chat %d for %s", userID, chatID, wait.Round(time.Second))
+	sendText(chatID, tr(messageLanguage(message), "mention.throttled",
+		formatDuration(now.Sub(last.At)), last.UserName, formatDuration(wait)))
+	return false
+}
+
+// recordMentionSummon starts the cooldown after a successful mass mention
+func recordMentionSummon(message *tgbotapi.Message) {
+	cooldowns.RecordSummon(message.Chat.ID, mentionSummon{
+		UserID:   message.From.ID,
+		UserName: displayName(message.From),
+		At:       time.Now(),
+	})
+}
+
+// handleCooldownCommand shows or changes the chat's mention cooldowns, e.g. /cooldown chat 2m
+func handleCooldownCommand(update tgbotapi.Update, ulog updateLog) {
+	chatID := update.Message.Chat.ID
+	lang := messageLanguage(update.Message)
+	args := strings.Fields(update.Message.CommandArguments())
+
+	settings, err := getChatSettings(chatID)
+	if err != nil {
+		ulog.Error("Failed to load settings for chat %d: %v", chatID, err)
+		sendText(chatID, tr(lang, "settings.load_failed"))
+		return
+	}
+
+	if len(args) == 0 {
+		sendText(chatID, tr(lang, "cooldown.show", formatDuration(settings.ChatCooldown), formatDuration(settings.UserCooldown)))
+		return
+	}
+
+	if !isChatAdmin(chatID, update.Message.From.ID, ulog) {
+		sendText(chatID, tr(lang, "cooldown.admins_only"))
+		return
+	}
+
+	if len(args) != 2 || (args[0] != "chat" && args[0] != "user") {
+		sendText(chatID, tr(lang, "cooldown.usage"))
+		return
+	}
+
+	duration, err := parseDuration(args[1])
+	if args[1] == "0" || strings.EqualFold(args[1], "off") {
+		duration, err = 0, nil
+	}
+	if err != nil || duration < 0 || duration > 24*time.Hour {
+		sendText(chatID, tr(lang, "cooldown.invalid"))
+		return
+	}
+
+	if args[0] == "chat" {
+		settings.ChatCooldown = duration
+	} else {
+		settings.UserCooldown = duration
+	}
+	if err := setChatCooldowns(chatID, settings.ChatCooldown, settings.UserCooldown); err != nil {
+		ulog.Error("Failed to save cooldowns for chat %d: %v", chatID, err)
+		sendText(chatID, tr(lang, "settings.save_failed"))
+		return
+	}
+	sendText(chatID, tr(lang, "cooldown.updated", args[0], formatDuration(duration)))
+}
@@ -1,46 +1,96 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"os"
+	"slices"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
+// initDB connects to the database and brings the schema up to date, unless AUTO_MIGRATE
+// is false, in which case it refuses to start on an outdated schema
 func initDB() {
+	openDB()
+
+	ctx := context.Background()
+	if os.Getenv("AUTO_MIGRATE") == "false" {
+		pending, err := pendingMigrations(ctx)
+		if err != nil {
+			LogFatal("Failed to check migrations: %v", err)
+		}
+		if pending > 0 {
+			LogFatal("%d migrations are pending, run `tagbot migrate up` first", pending)
+		}
+	} else if _, err := migrateUp(ctx); err != nil {
+		LogFatal("Failed to migrate database: %v", err)
+	}
+	LogInfo("Database initialized successfully")
+}
+
+// openDB connects to DATABASE_URL
+func openDB() {
 	dsn := os.Getenv("DATABASE_URL")
 	if dsn == "" {
 		LogFatal("Missing DATABASE_URL environment variable")
 	}
 
-	var err error
-	db, err = sql.Open("postgres", dsn)
+	sqlDB, err := sql.Open("postgres", dsn)
 	if err != nil {
 		LogFatal("Failed to open DB: %v", err)
 	}
+	db = &instrumentedDB{DB: sqlDB}
 
 	err = db.Ping()
 	if err != nil {
 		LogFatal("Failed to ping DB: %v", err)
 	}
+}
 
-	// Create tables if not exist
-	schema := `
-	CREATE TABLE IF NOT EXISTS members (
-		chat_id BIGINT NOT NULL,
-		user_id BIGINT NOT NULL,
-		first_name TEXT,
-		last_name TEXT,
-		username TEXT,
-		PRIMARY KEY (chat_id, user_id)
-	);
+// loadOrStoreBotState returns the stored value of a bot-wide key, storing value first if the
+// key is not set yet. Concurrent callers all get the value of whoever stored it first.
+func loadOrStoreBotState(key, value string) (string, error) {
+	query := `
+	INSERT INTO bot_state (key, value) VALUES ($1, $2)
+	ON CONFLICT (key) DO NOTHING;
 	`
-	if _, err := db.Exec(schema); err != nil {
-		LogFatal("Failed to create tables: %v", err)
+	if _, err := db.Exec(query, key, value); err != nil {
+		return "", fmt.Errorf("store bot state %s failed: %w", key, err)
 	}
-	LogInfo("Database initialized successfully")
+	var stored string
+	if err := db.QueryRow("SELECT value FROM bot_state WHERE key = $1", key).Scan(&stored); err != nil {
+		return "", fmt.Errorf("load bot state %s failed: %w", key, err)
+	}
+	return stored, nil
+}
+
+// getBotState returns the value of a bot-wide key, or "" if it is not set
+func getBotState(key string) (string, error) {
+	var value string
+	err := db.QueryRow("SELECT value FROM bot_state WHERE key = $1", key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("load bot state %s failed: %w", key, err)
+	}
+	return value, nil
+}
+
+// setBotState stores the value of a bot-wide key
+func setBotState(key, value string) error {
+	query := `
+	INSERT INTO bot_state (key, value) VALUES ($1, $2)
+	ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = NOW();
+	`
+	if _, err := db.Exec(query, key, value); err != nil {
+		return fmt.Errorf("store bot state %s failed: %w", key, err)
+	}
+	return nil
 }
 
 func saveUser(chatID int64, user *tgbotapi.User) {
@@ -66,3 +116,776 @@ func deleteUser(chatID int64, userID int64) error {
 	LogInfo("Deleted user %d from chat %d", userID, chatID)
 	return nil
 }
+
+// countMembers returns the number of known members of a chat
+func countMembers(chatID int64) (int, error) {
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM members WHERE chat_id = $1", chatID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count members failed: %w", err)
+	}
+	return count, nil
+}
+
+// setUserMuted opts a member in or out of mass mentions in a chat
+func setUserMuted(chatID int64, userID int64, muted bool) error {
+	if _, err := db.Exec("UPDATE members SET muted = $3 WHERE chat_id = $1 AND user_id = $2", chatID, userID, muted); err != nil {
+		return fmt.Errorf("set user muted failed: %w", err)
+	}
+	LogInfo("Set muted=%t for user %d in chat %d", muted, userID, chatID)
+	return nil
+}
+
+// setUserDND excludes a member from mass mentions until the given time; a nil time clears it
+func setUserDND(chatID int64, userID int64, until *time.Time) error {
+	if _, err := db.Exec("UPDATE members SET dnd_until = $3 WHERE chat_id = $1 AND user_id = $2", chatID, userID, until); err != nil {
+		return fmt.Errorf("set user dnd failed: %w", err)
+	}
+	LogInfo("Set dnd_until=%v for user %d in chat %d", until, userID, chatID)
+	return nil
+}
+
+// chatSettings holds the per-chat configuration, with defaults filled in for unset values
+type chatSettings struct {
+	ChatCooldown     time.Duration
+	UserCooldown     time.Duration
+	MentionPolicy    string
+	Timezone         string
+	Language         string // empty means follow each user's Telegram language
+	ForwardToSpecial bool
+	PollsEnabled     bool
+	Persona          string
+	StartTemplate    string // empty means use the persona's text
+	HelpTemplate     string // empty means use the persona's text
+}
+
+func defaultChatSettings() chatSettings {
+	return chatSettings{
+		ChatCooldown:     defaultChatCooldown,
+		UserCooldown:     defaultUserCooldown,
+		MentionPolicy:    mentionPolicyEveryone,
+		Timezone:         defaultTimezone,
+		ForwardToSpecial: true,
+		PollsEnabled:     true,
+		Persona:          defaultPersona,
+	}
+}
+
+func getChatSettings(chatID int64) (chatSettings, error) {
+	settings := defaultChatSettings()
+
+	var chatCooldown, userCooldown sql.NullInt64
+	var mentionPolicy, timezone, language, persona, startTemplate, helpTemplate sql.NullString
+	var forwardToSpecial, pollsEnabled sql.NullBool
+	err := db.QueryRow(`
+	SELECT chat_cooldown_seconds, user_cooldown_seconds, mention_policy, timezone,
+		language, forward_to_special, polls_enabled, persona, start_template, help_template
+	FROM chat_settings WHERE chat_id = $1
+	`, chatID).Scan(&chatCooldown, &userCooldown, &mentionPolicy, &timezone,
+		&language, &forwardToSpecial, &pollsEnabled, &persona, &startTemplate, &helpTemplate)
+	if err == sql.ErrNoRows {
+		return settings, nil
+	}
+	if err != nil {
+		return settings, fmt.Errorf("get chat settings failed: %w", err)
+	}
+
+	if chatCooldown.Valid {
+		settings.ChatCooldown = time.Duration(chatCooldown.Int64) * time.Second
+	}
+	if userCooldown.Valid {
+		settings.UserCooldown = time.Duration(userCooldown.Int64) * time.Second
+	}
+	if mentionPolicy.Valid {
+		settings.MentionPolicy = mentionPolicy.String
+	}
+	if timezone.Valid {
+		settings.Timezone = timezone.String
+	}
+	if language.Valid {
+		settings.Language = language.String
+	}
+	if forwardToSpecial.Valid {
+		settings.ForwardToSpecial = forwardToSpecial.Bool
+	}
+	if pollsEnabled.Valid {
+		settings.PollsEnabled = pollsEnabled.Bool
+	}
+	if persona.Valid {
+		settings.Persona = persona.String
+	}
+	settings.StartTemplate = startTemplate.String
+	settings.HelpTemplate = helpTemplate.String
+	return settings, nil
+}
+
+// chatSettingColumns are the chat_settings columns that setChatSetting may update
+var chatSettingColumns = []string{
+	"chat_cooldown_seconds",
+	"user_cooldown_seconds",
+	"mention_policy",
+	"timezone",
+	"language",
+	"forward_to_special",
+	"polls_enabled",
+	"persona",
+	"start_template",
+	"help_template",
+}
+
+// setChatSetting stores a single chat_settings column for a chat
+func setChatSetting(chatID int64, column string, value interface{}) error {
+	if !slices.Contains(chatSettingColumns, column) {
+		return fmt.Errorf("unknown chat setting %q", column)
+	}
+	query := fmt.Sprintf(`
+	INSERT INTO chat_settings (chat_id, %[1]s)
+	VALUES ($1, $2)
+	ON CONFLICT (chat_id) DO UPDATE SET %[1]s = EXCLUDED.%[1]s;
+	`, column)
+	if _, err := db.Exec(query, chatID, value); err != nil {
+		return fmt.Errorf("set chat setting %s failed: %w", column, err)
+	}
+	LogInfo("Set %s for chat %d to %v", column, chatID, value)
+	return nil
+}
+
+func setChatCooldowns(chatID int64, chatCooldown, userCooldown time.Duration) error {
+	query := `
+	INSERT INTO chat_settings (chat_id, chat_cooldown_seconds, user_cooldown_seconds)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (chat_id) DO UPDATE SET
+		chat_cooldown_seconds = EXCLUDED.chat_cooldown_seconds,
+		user_cooldown_seconds = EXCLUDED.user_cooldown_seconds;
+	`
+	if _, err := db.Exec(query, chatID, int64(chatCooldown.Seconds()), int64(userCooldown.Seconds())); err != nil {
+		return fmt.Errorf("set chat cooldowns failed: %w", err)
+	}
+	LogInfo("Set cooldowns for chat %d: chat=%s user=%s", chatID, chatCooldown, userCooldown)
+	return nil
+}
+
+func setMentionPolicy(chatID int64, policy string) error {
+	return setChatSetting(chatID, "mention_policy", policy)
+}
+
+func setChatTimezone(chatID int64, timezone string) error {
+	return setChatSetting(chatID, "timezone", timezone)
+}
+
+func isUserAllowlisted(chatID int64, userID int64) (bool, error) {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM mention_allowlist WHERE chat_id = $1 AND user_id = $2)", chatID, userID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("check mention allowlist failed: %w", err)
+	}
+	return exists, nil
+}
+
+func addToAllowlist(chatID int64, userID int64, addedBy int64) error {
+	_, err := db.Exec(`
+	INSERT INTO mention_allowlist (chat_id, user_id, added_by)
+	VALUES ($1, $2, $3)
+	ON CONFLICT DO NOTHING;
+	`, chatID, userID, addedBy)
+	if err != nil {
+		return fmt.Errorf("add to mention allowlist failed: %w", err)
+	}
+	return nil
+}
+
+func removeFromAllowlist(chatID int64, userID int64) error {
+	if _, err := db.Exec("DELETE FROM mention_allowlist WHERE chat_id = $1 AND user_id = $2", chatID, userID); err != nil {
+		return fmt.Errorf("remove from mention allowlist failed: %w", err)
+	}
+	return nil
+}
+
+// listAllowlistNames returns display names of the allow-listed users known in members
+func listAllowlistNames(chatID int64) ([]string, error) {
+	rows, err := db.Query(`
+	SELECT a.user_id, COALESCE(m.first_name, ''), COALESCE(m.last_name, ''), COALESCE(m.username, '')
+	FROM mention_allowlist a
+	LEFT JOIN members m ON m.chat_id = a.chat_id AND m.user_id = a.user_id
+	WHERE a.chat_id = $1
+	ORDER BY a.user_id
+	`, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("list mention allowlist failed: %w", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var user tgbotapi.User
+		if err := rows.Scan(&user.ID, &user.FirstName, &user.LastName, &user.UserName); err != nil {
+			return nil, fmt.Errorf("scan mention allowlist failed: %w", err)
+		}
+		name := displayName(&user)
+		if name == "" {
+			name = fmt.Sprintf("user %d", user.ID)
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// saveRelayAudit records an item relayed with @sendto; sendErr is stored if the send failed
+func saveRelayAudit(operatorID, sourceChatID, targetChatID int64, kind, content string, sendErr error) error {
+	var errText sql.NullString
+	if sendErr != nil {
+		errText = sql.NullString{String: sendErr.Error(), Valid: true}
+	}
+	query := `
+	INSERT INTO relay_audit (operator_id, source_chat_id, target_chat_id, kind, content, error)
+	VALUES ($1, $2, $3, $4, $5, $6);
+	`
+	if _, err := db.Exec(query, operatorID, sourceChatID, targetChatID, kind, content, errText); err != nil {
+		return fmt.Errorf("save relay audit failed: %w", err)
+	}
+	return nil
+}
+
+// saveRelayMessage maps a message copied into a special chat to its origin
+func saveRelayMessage(specialChatID int64, specialMessageID int, originChatID int64, originMessageID int) error {
+	query := `
+	INSERT INTO relay_messages (special_chat_id, special_message_id, origin_chat_id, origin_message_id)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (special_chat_id, special_message_id) DO UPDATE SET
+		origin_chat_id = EXCLUDED.origin_chat_id,
+		origin_message_id = EXCLUDED.origin_message_id;
+	`
+	if _, err := db.Exec(query, specialChatID, specialMessageID, originChatID, originMessageID); err != nil {
+		return fmt.Errorf("save relay message failed: %w", err)
+	}
+	return nil
+}
+
+// findRelayOrigin returns the origin of a message in a special chat, or sql.ErrNoRows
+func findRelayOrigin(specialChatID int64, specialMessageID int) (int64, int, error) {
+	var originChatID int64
+	var originMessageID int
+	err := db.QueryRow(`
+	SELECT origin_chat_id, origin_message_id FROM relay_messages
+	WHERE special_chat_id = $1 AND special_message_id = $2
+	`, specialChatID, specialMessageID).Scan(&originChatID, &originMessageID)
+	if err != nil {
+		return 0, 0, err
+	}
+	return originChatID, originMessageID, nil
+}
+
+func createMentionGroup(chatID int64, groupName string, createdBy int64) (bool, error) {
+	res, err := db.Exec(`
+	INSERT INTO mention_groups (chat_id, group_name, created_by)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (chat_id, group_name) DO NOTHING;
+	`, chatID, groupName, createdBy)
+	if err != nil {
+		return false, fmt.Errorf("create mention group failed: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+func deleteMentionGroup(chatID int64, groupName string) (bool, error) {
+	res, err := db.Exec("DELETE FROM mention_groups WHERE chat_id = $1 AND group_name = $2", chatID, groupName)
+	if err != nil {
+		return false, fmt.Errorf("delete mention group failed: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+func mentionGroupExists(chatID int64, groupName string) (bool, error) {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM mention_groups WHERE chat_id = $1 AND group_name = $2)", chatID, groupName).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("check mention group failed: %w", err)
+	}
+	return exists, nil
+}
+
+// listMentionGroups returns every group defined in the chat with its member count
+func listMentionGroups(chatID int64) (map[string]int, error) {
+	rows, err := db.Query(`
+	SELECT g.group_name, COUNT(m.user_id)
+	FROM mention_groups g
+	LEFT JOIN mention_group_members m ON m.chat_id = g.chat_id AND m.group_name = g.group_name
+	WHERE g.chat_id = $1
+	GROUP BY g.group_name
+	`, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("list mention groups failed: %w", err)
+	}
+	defer rows.Close()
+
+	groups := make(map[string]int)
+	for rows.Next() {
+		var name string
+		var count int
+		if err := rows.Scan(&name, &count); err != nil {
+			return nil, fmt.Errorf("scan mention group failed: %w", err)
+		}
+		groups[name] = count
+	}
+	return groups, rows.Err()
+}
+
+// filterMentionGroups returns the subset of names that are defined groups in the chat
+func filterMentionGroups(chatID int64, names []string) ([]string, error) {
+	rows, err := db.Query("SELECT group_name FROM mention_groups WHERE chat_id = $1 AND group_name = ANY($2)", chatID, pq.Array(names))
+	if err != nil {
+		return nil, fmt.Errorf("filter mention groups failed: %w", err)
+	}
+	defer rows.Close()
+
+	var groups []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("scan mention group failed: %w", err)
+		}
+		groups = append(groups, name)
+	}
+	return groups, rows.Err()
+}
+
+func addMentionGroupMember(chatID int64, groupName string, userID int64) error {
+	_, err := db.Exec(`
+	INSERT INTO mention_group_members (chat_id, group_name, user_id)
+	VALUES ($1, $2, $3)
+	ON CONFLICT DO NOTHING;
+	`, chatID, groupName, userID)
+	if err != nil {
+		return fmt.Errorf("add mention group member failed: %w", err)
+	}
+	return nil
+}
+
+func removeMentionGroupMember(chatID int64, groupName string, userID int64) error {
+	_, err := db.Exec("DELETE FROM mention_group_members WHERE chat_id = $1 AND group_name = $2 AND user_id = $3", chatID, groupName, userID)
+	if err != nil {
+		return fmt.Errorf("remove mention group member failed: %w", err)
+	}
+	return nil
+}
+
+// findUserIDByUsername looks up a known member of the chat by their @username
+func findUserIDByUsername(chatID int64, username string) (int64, error) {
+	var userID int64
+	err := db.QueryRow("SELECT user_id FROM members WHERE chat_id = $1 AND LOWER(username) = LOWER($2)", chatID, username).Scan(&userID)
+	if err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+// pollSchedule is a daily poll posted in a chat at a local time of day
+type pollSchedule struct {
+	ID           int64
+	ChatID       int64
+	PollTime     string
+	Question     string
+	Options      []string
+	Timezone     string
+	LastSentDate sql.NullTime
+
+	// Optional automatic reminder, RemindBefore minutes before GameTime
+	GameTime         sql.NullString
+	RemindBefore     sql.NullInt64
+	LastRemindedDate sql.NullTime
+}
+
+// createPollSchedule stores a new schedule. lastSentDate marks today as done when the
+// poll time has already passed, so a new schedule doesn't fire immediately.
+func createPollSchedule(chatID int64, pollTime, question string, options []string, lastSentDate *time.Time, createdBy int64) (int64, error) {
+	// Store the local calendar date, not an instant that Postgres would convert to its own zone
+	var sentDate sql.NullString
+	if lastSentDate != nil {
+		sentDate = sql.NullString{String: lastSentDate.Format("2006-01-02"), Valid: true}
+	}
+
+	var id int64
+	err := db.QueryRow(`
+	INSERT INTO poll_schedules (chat_id, poll_time, question, options, last_sent_date, created_by)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id
+	`, chatID, pollTime, question, pq.Array(options), sentDate, createdBy).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("create poll schedule failed: %w", err)
+	}
+	LogInfo("Created poll schedule %d in chat %d at %s", id, chatID, pollTime)
+	return id, nil
+}
+
+func deletePollSchedule(chatID int64, id int64) (bool, error) {
+	res, err := db.Exec("DELETE FROM poll_schedules WHERE chat_id = $1 AND id = $2", chatID, id)
+	if err != nil {
+		return false, fmt.Errorf("delete poll schedule failed: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// listPollSchedules returns the schedules of one chat, or of every chat if chatID is 0
+func listPollSchedules(chatID int64) ([]pollSchedule, error) {
+	rows, err := db.Query(`
+	SELECT p.id, p.chat_id, p.poll_time, p.question, p.options, COALESCE(s.timezone, $2), p.last_sent_date,
+		p.game_time, p.remind_before_minutes, p.last_reminded_date
+	FROM poll_schedules p
+	LEFT JOIN chat_settings s ON s.chat_id = p.chat_id
+	LEFT JOIN chats c ON c.chat_id = p.chat_id
+	WHERE ($1::bigint = 0 AND COALESCE(c.active, TRUE) AND COALESCE(s.polls_enabled, TRUE)) OR p.chat_id = $1
+	ORDER BY p.chat_id, p.poll_time, p.id
+	`, chatID, defaultTimezone)
+	if err != nil {
+		return nil, fmt.Errorf("list poll schedules failed: %w", err)
+	}
+	defer rows.Close()
+
+	var schedules []pollSchedule
+	for rows.Next() {
+		var schedule pollSchedule
+		if err := rows.Scan(&schedule.ID, &schedule.ChatID, &schedule.PollTime, &schedule.Question,
+			pq.Array(&schedule.Options), &schedule.Timezone, &schedule.LastSentDate,
+			&schedule.GameTime, &schedule.RemindBefore, &schedule.LastRemindedDate); err != nil {
+			return nil, fmt.Errorf("scan poll schedule failed: %w", err)
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, rows.Err()
+}
+
+// claimPollSchedule marks a schedule as sent for the given local date. It returns false if
+// the poll was already sent that day, e.g. by another replica.
+func claimPollSchedule(id int64, date time.Time) (bool, error) {
+	res, err := db.Exec(`
+	UPDATE poll_schedules SET last_sent_date = $2
+	WHERE id = $1 AND (last_sent_date IS NULL OR last_sent_date < $2)
+	`, id, date.Format("2006-01-02"))
+	if err != nil {
+		return false, fmt.Errorf("claim poll schedule failed: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// setPollReminder sets the game time and reminder offset of a schedule; an empty gameTime disables it
+func setPollReminder(chatID int64, id int64, gameTime string, remindBefore int) (bool, error) {
+	var game sql.NullString
+	var minutes sql.NullInt64
+	if gameTime != "" {
+		game = sql.NullString{String: gameTime, Valid: true}
+		minutes = sql.NullInt64{Int64: int64(remindBefore), Valid: true}
+	}
+	res, err := db.Exec(`
+	UPDATE poll_schedules SET game_time = $3, remind_before_minutes = $4
+	WHERE chat_id = $1 AND id = $2
+	`, chatID, id, game, minutes)
+	if err != nil {
+		return false, fmt.Errorf("set poll reminder failed: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// claimPollReminder marks a schedule's reminder as sent for the given local date. It returns
+// false if the reminder was already sent that day.
+func claimPollReminder(id int64, date time.Time) (bool, error) {
+	res, err := db.Exec(`
+	UPDATE poll_schedules SET last_reminded_date = $2
+	WHERE id = $1 AND (last_reminded_date IS NULL OR last_reminded_date < $2)
+	`, id, date.Format("2006-01-02"))
+	if err != nil {
+		return false, fmt.Errorf("claim poll reminder failed: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// savePoll records a poll posted by the bot so its votes can be tracked
+func savePoll(pollID string, chatID int64, messageID int, pollDate time.Time, question string, options []string, scheduleID int64) error {
+	var schedule sql.NullInt64
+	if scheduleID != 0 {
+		schedule = sql.NullInt64{Int64: scheduleID, Valid: true}
+	}
+	query := `
+	INSERT INTO polls (poll_id, chat_id, message_id, poll_date, question, options, schedule_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	ON CONFLICT (poll_id) DO NOTHING;
+	`
+	if _, err := db.Exec(query, pollID, chatID, messageID, pollDate.Format("2006-01-02"), question, pq.Array(options), schedule); err != nil {
+		return fmt.Errorf("save poll failed: %w", err)
+	}
+	LogInfo("Saved poll %s in chat %d", pollID, chatID)
+	return nil
+}
+
+// saveVote stores a user's answer to a tracked poll; an empty answer retracts the vote.
+// Votes on polls the bot didn't post are ignored.
+func saveVote(pollID string, userID int64, optionIDs []int) error {
+	if len(optionIDs) == 0 {
+		if _, err := db.Exec("DELETE FROM poll_votes WHERE poll_id = $1 AND user_id = $2", pollID, userID); err != nil {
+			return fmt.Errorf("retract vote failed: %w", err)
+		}
+		return nil
+	}
+
+	ids := make([]int64, len(optionIDs))
+	for i, id := range optionIDs {
+		ids[i] = int64(id)
+	}
+	query := `
+	INSERT INTO poll_votes (poll_id, user_id, option_ids)
+	SELECT poll_id, $2, $3 FROM polls WHERE poll_id = $1
+	ON CONFLICT (poll_id, user_id) DO UPDATE SET
+		option_ids = EXCLUDED.option_ids,
+		voted_at = NOW();
+	`
+	if _, err := db.Exec(query, pollID, userID, pq.Array(ids)); err != nil {
+		return fmt.Errorf("save vote failed: %w", err)
+	}
+	return nil
+}
+
+// findPollChatID returns the chat a tracked poll was posted in, or sql.ErrNoRows
+func findPollChatID(pollID string) (int64, error) {
+	var chatID int64
+	err := db.QueryRow("SELECT chat_id FROM polls WHERE poll_id = $1", pollID).Scan(&chatID)
+	return chatID, err
+}
+
+// trackedPoll is a poll posted by the bot
+type trackedPoll struct {
+	PollID   string
+	ChatID   int64
+	Question string
+	Options  []string
+}
+
+// findLatestPoll returns the most recent tracked poll in the chat for a date, or sql.ErrNoRows
+func findLatestPoll(chatID int64, date time.Time) (trackedPoll, error) {
+	poll := trackedPoll{ChatID: chatID}
+	err := db.QueryRow(`
+	SELECT poll_id, question, options FROM polls
+	WHERE chat_id = $1 AND poll_date = $2
+	ORDER BY created_at DESC LIMIT 1
+	`, chatID, date.Format("2006-01-02")).Scan(&poll.PollID, &poll.Question, pq.Array(&poll.Options))
+	return poll, err
+}
+
+// findSchedulePoll returns the poll posted for a schedule on a date, or sql.ErrNoRows
+func findSchedulePoll(scheduleID int64, date time.Time) (trackedPoll, error) {
+	var poll trackedPoll
+	err := db.QueryRow(`
+	SELECT poll_id, chat_id, question, options FROM polls
+	WHERE schedule_id = $1 AND poll_date = $2
+	ORDER BY created_at DESC LIMIT 1
+	`, scheduleID, date.Format("2006-01-02")).Scan(&poll.PollID, &poll.ChatID, &poll.Question, pq.Array(&poll.Options))
+	return poll, err
+}
+
+// pollVoter is a member's answer to a poll
+type pollVoter struct {
+	User      tgbotapi.User
+	OptionIDs []int64
+}
+
+// listPollVoters returns the votes on a poll with the voters' names from members
+func listPollVoters(pollID string) ([]pollVoter, error) {
+	rows, err := db.Query(`
+	SELECT v.user_id, COALESCE(m.first_name, ''), COALESCE(m.last_name, ''), COALESCE(m.username, ''), v.option_ids
+	FROM poll_votes v
+	JOIN polls p ON p.poll_id = v.poll_id
+	LEFT JOIN members m ON m.chat_id = p.chat_id AND m.user_id = v.user_id
+	WHERE v.poll_id = $1
+	ORDER BY v.voted_at
+	`, pollID)
+	if err != nil {
+		return nil, fmt.Errorf("list poll voters failed: %w", err)
+	}
+	defer rows.Close()
+
+	var voters []pollVoter
+	for rows.Next() {
+		var voter pollVoter
+		if err := rows.Scan(&voter.User.ID, &voter.User.FirstName, &voter.User.LastName, &voter.User.UserName, pq.Array(&voter.OptionIDs)); err != nil {
+			return nil, fmt.Errorf("scan poll voter failed: %w", err)
+		}
+		voters = append(voters, voter)
+	}
+	return voters, rows.Err()
+}
+
+// memberAttendance summarizes a member's answers to the chat's polls over a period
+type memberAttendance struct {
+	User     tgbotapi.User
+	Yes      int
+	Other    int
+	Answered int
+}
+
+// getAttendanceSummary counts, for every member of the chat, how many polls since the given
+// date they answered with the first option (yes), with another option, or at all.
+// It also returns the number of polls in the period.
+func getAttendanceSummary(chatID int64, since time.Time) ([]memberAttendance, int, error) {
+	var total int
+	if err := db.QueryRow("SELECT COUNT(*) FROM polls WHERE chat_id = $1 AND poll_date >= $2", chatID, since.Format("2006-01-02")).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("count polls failed: %w", err)
+	}
+
+	rows, err := db.Query(`
+	SELECT m.user_id, m.first_name, m.last_name, m.username,
+		COUNT(v.poll_id) FILTER (WHERE 0 = ANY(v.option_ids)),
+		COUNT(v.poll_id) FILTER (WHERE NOT 0 = ANY(v.option_ids)),
+		COUNT(v.poll_id)
+	FROM members m
+	LEFT JOIN (
+		SELECT v.poll_id, v.user_id, v.option_ids
+		FROM poll_votes v
+		JOIN polls p ON p.poll_id = v.poll_id
+		WHERE p.chat_id = $1 AND p.poll_date >= $2
+	) v ON v.user_id = m.user_id
+	WHERE m.chat_id = $1
+	GROUP BY m.user_id, m.first_name, m.last_name, m.username
+	ORDER BY 5 DESC, 7 DESC, m.first_name
+	`, chatID, since.Format("2006-01-02"))
+	if err != nil {
+		return nil, 0, fmt.Errorf("get attendance summary failed: %w", err)
+	}
+	defer rows.Close()
+
+	var summary []memberAttendance
+	for rows.Next() {
+		var row memberAttendance
+		if err := rows.Scan(&row.User.ID, &row.User.FirstName, &row.User.LastName, &row.User.UserName, &row.Yes, &row.Other, &row.Answered); err != nil {
+			return nil, 0, fmt.Errorf("scan attendance failed: %w", err)
+		}
+		summary = append(summary, row)
+	}
+	return summary, total, rows.Err()
+}
+
+// setChatActive records whether the bot is currently in a chat
+func setChatActive(chatID int64, title string, active bool) error {
+	query := `
+	INSERT INTO chats (chat_id, title, active, left_at)
+	VALUES ($1, $2, $3, CASE WHEN $3 THEN NULL ELSE NOW() END)
+	ON CONFLICT (chat_id) DO UPDATE SET
+		title = EXCLUDED.title,
+		active = EXCLUDED.active,
+		left_at = EXCLUDED.left_at;
+	`
+	if _, err := db.Exec(query, chatID, title, active); err != nil {
+		return fmt.Errorf("set chat active failed: %w", err)
+	}
+	LogInfo("Set active=%t for chat %d", active, chatID)
+	return nil
+}
+
+// chatScopedTables are the tables keyed by chat_id whose rows move on migration and
+// are removed on purge. Order matters for the foreign key of mention_group_members.
+var chatScopedTables = []struct {
+	name    string
+	columns string
+}{
+	{"members", "user_id, first_name, last_name, username, muted, dnd_until"},
+	{"chat_settings", "chat_cooldown_seconds, user_cooldown_seconds, mention_policy, timezone, language, forward_to_special, polls_enabled, persona, start_template, help_template"},
+	{"mention_allowlist", "user_id, added_by"},
+	{"mention_groups", "group_name, created_by, created_at"},
+	{"mention_group_members", "group_name, user_id"},
+}
+
+// migrateChatData moves all per-chat data from a group to the supergroup it was upgraded to,
+// in one transaction. Rows that already exist under the new ID are kept.
+func migrateChatData(oldChatID, newChatID int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin chat migration failed: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, table := range chatScopedTables {
+		query := fmt.Sprintf(`
+		INSERT INTO %[1]s (chat_id, %[2]s)
+		SELECT $2, %[2]s FROM %[1]s WHERE chat_id = $1
+		ON CONFLICT DO NOTHING
+		`, table.name, table.columns)
+		if _, err := tx.Exec(query, oldChatID, newChatID); err != nil {
+			return fmt.Errorf("migrate %s failed: %w", table.name, err)
+		}
+	}
+	// Delete in reverse order so group members go before their groups
+	for i := len(chatScopedTables) - 1; i >= 0; i-- {
+		if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE chat_id = $1", chatScopedTables[i].name), oldChatID); err != nil {
+			return fmt.Errorf("clean up %s failed: %w", chatScopedTables[i].name, err)
+		}
+	}
+
+	updates := []string{
+		"UPDATE poll_schedules SET chat_id = $2 WHERE chat_id = $1",
+		"UPDATE polls SET chat_id = $2 WHERE chat_id = $1",
+		"UPDATE relay_messages SET origin_chat_id = $2 WHERE origin_chat_id = $1",
+		"UPDATE chats SET active = FALSE, left_at = NOW() WHERE chat_id = $1",
+	}
+	for _, query := range updates {
+		if _, err := tx.Exec(query, oldChatID, newChatID); err != nil {
+			return fmt.Errorf("migrate chat failed: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit chat migration failed: %w", err)
+	}
+	LogInfo("Migrated chat %d to %d", oldChatID, newChatID)
+	return nil
+}
+
+// purgeInactiveChats deletes the data of chats the bot left before the cutoff and
+// returns how many chats were purged
+func purgeInactiveChats(cutoff time.Time) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("begin purge failed: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT chat_id FROM chats WHERE NOT active AND left_at < $1", cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("list inactive chats failed: %w", err)
+	}
+	var chatIDs []int64
+	for rows.Next() {
+		var chatID int64
+		if err := rows.Scan(&chatID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan inactive chat failed: %w", err)
+		}
+		chatIDs = append(chatIDs, chatID)
+	}
+	rows.Close()
+	if len(chatIDs) == 0 {
+		return 0, nil
+	}
+
+	queries := []string{
+		"DELETE FROM poll_schedules WHERE chat_id = ANY($1)",
+		"DELETE FROM polls WHERE chat_id = ANY($1)",
+		"DELETE FROM relay_messages WHERE origin_chat_id = ANY($1)",
+	}
+	for i := len(chatScopedTables) - 1; i >= 0; i-- {
+		queries = append(queries, fmt.Sprintf("DELETE FROM %s WHERE chat_id = ANY($1)", chatScopedTables[i].name))
+	}
+	queries = append(queries, "DELETE FROM chats WHERE chat_id = ANY($1)")
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query, pq.Array(chatIDs)); err != nil {
+			return 0, fmt.Errorf("purge chats failed: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit purge failed: %w", err)
+	}
+	return len(chatIDs), nil
+}
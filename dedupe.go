@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// pollingOffsetStateKey is the bot_state key of the last update ID processed from polling.
+// Update IDs are per bot, so the key includes the bot's ID in case the token changes.
+func pollingOffsetStateKey() string {
+	return "polling_offset:" + strconv.FormatInt(bot.Self.ID, 10)
+}
+
+// seenUpdateTTL is how long webhook update IDs are remembered. Telegram retries a
+// failed delivery within minutes, so this comfortably covers redeliveries.
+const seenUpdateTTL = 15 * time.Minute
+
+// updateSet remembers recently seen update IDs for seenUpdateTTL
+type updateSet struct {
+	mu         sync.Mutex
+	seen       map[int]time.Time
+	lastPruned time.Time
+}
+
+func newUpdateSet() *updateSet {
+	return &updateSet{seen: make(map[int]time.Time)}
+}
+
+// Add records an update ID and reports whether it was new
+func (s *updateSet) Add(updateID int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastPruned) > seenUpdateTTL {
+		for id, seenAt := range s.seen {
+			if now.Sub(seenAt) > seenUpdateTTL {
+				delete(s.seen, id)
+			}
+		}
+		s.lastPruned = now
+	}
+
+	if seenAt, ok := s.seen[updateID]; ok && now.Sub(seenAt) <= seenUpdateTTL {
+		return false
+	}
+	s.seen[updateID] = now
+	return true
+}
+
+// Remove forgets an update ID, e.g. when the update was refused and will be redelivered
+func (s *updateSet) Remove(updateID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.seen, updateID)
+}
+
+// seenWebhookUpdates filters out webhook deliveries Telegram retried after a timeout
+var seenWebhookUpdates = newUpdateSet()
+
+// pollingOffsetFlushInterval is how often the processed polling offset is saved. After a
+// crash, updates processed since the last save are received and handled again.
+const pollingOffsetFlushInterval = 2 * time.Second
+
+// pollingRetryDelay is how long polling waits after a failed getUpdates
+const pollingRetryDelay = time.Second
+
+// maxUpdatesPerRequest is the most updates getUpdates returns at once
+const maxUpdatesPerRequest = 100
+
+// pollingOffsets tracks the updates received by polling until they are processed. It is nil
+// in webhook mode.
+var pollingOffsets *offsetTracker
+
+// offsetTracker finds the highest update ID up to which every received update has been
+// processed. Workers finish updates out of order, so a later update being done doesn't
+// mean the ones before it are.
+type offsetTracker struct {
+	mu        sync.Mutex
+	received  int   // highest update ID handed to the workers
+	pending   []int // received and not yet committed, in the order received
+	done      map[int]bool
+	committed int           // every update up to this ID is processed
+	advanced  chan struct{} // closed and replaced whenever committed moves
+	saved     int
+}
+
+// newOffsetTracker starts tracking at the offset polling resumes from
+func newOffsetTracker(offset int) *offsetTracker {
+	return &offsetTracker{
+		received:  offset - 1,
+		committed: offset - 1,
+		saved:     offset - 1,
+		done:      make(map[int]bool),
+		advanced:  make(chan struct{}),
+	}
+}
+
+// Offset returns the offset for getUpdates and a channel that is closed once it advances.
+// Telegram forgets the updates before the offset, so it never goes past an update that
+// isn't processed yet.
+func (t *offsetTracker) Offset() (int, <-chan struct{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.committed + 1, t.advanced
+}
+
+// Received records an update handed to the workers. It reports false for an update that
+// was already received, which Telegram sends again until the offset moves past it.
+func (t *offsetTracker) Received(updateID int) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if updateID <= t.received {
+		return false
+	}
+	t.received = updateID
+	t.pending = append(t.pending, updateID)
+	return true
+}
+
+// Done records a processed update and advances the committed offset past every update
+// that is processed along with all updates received before it
+func (t *offsetTracker) Done(updateID int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.done[updateID] = true
+	committed := t.committed
+	for len(t.pending) > 0 && t.done[t.pending[0]] {
+		t.committed = t.pending[0]
+		delete(t.done, t.pending[0])
+		t.pending = t.pending[1:]
+	}
+	if t.committed != committed {
+		close(t.advanced)
+		t.advanced = make(chan struct{})
+	}
+}
+
+// Flush saves the committed offset if it changed since the last save
+func (t *offsetTracker) Flush() {
+	t.mu.Lock()
+	committed, saved := t.committed, t.saved
+	t.mu.Unlock()
+	if committed == saved {
+		return
+	}
+	if err := setBotState(pollingOffsetStateKey(), strconv.Itoa(committed)); err != nil {
+		LogError("Failed to save polling offset %d: %v", committed, err)
+		return
+	}
+	t.mu.Lock()
+	t.saved = max(t.saved, committed)
+	t.mu.Unlock()
+}
+
+// startPollingOffsetFlusher saves the processed polling offset every
+// pollingOffsetFlushInterval until ctx is done. Shutdown flushes once more after the workers
+// have drained.
+func startPollingOffsetFlusher(ctx context.Context) {
+	backgroundTasks.Add(1)
+	go func() {
+		defer backgroundTasks.Done()
+		ticker := time.NewTicker(pollingOffsetFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				pollingOffsets.Flush()
+			}
+		}
+	}()
+}
+
+// updatePoller long-polls Telegram and hands new updates to the workers. Unlike the
+// library's GetUpdatesChan it only confirms processed updates, so the ones still queued
+// when the bot stops are delivered again after a restart.
+type updatePoller struct {
+	getUpdates func(tgbotapi.UpdateConfig) ([]tgbotapi.Update, error)
+	submit     func(context.Context, tgbotapi.Update) bool
+	offsets    *offsetTracker
+
+	// limit caps the updates fetched at once. Only updates fetched from the unconfirmed
+	// offset are in progress, so with at most a worker queue's worth of them submitting
+	// never blocks polling on a busy chat.
+	limit int
+}
+
+// newUpdatePoller returns a poller feeding the worker pool
+func newUpdatePoller() *updatePoller {
+	return &updatePoller{
+		getUpdates: bot.GetUpdates,
+		submit:     workers.Submit,
+		offsets:    pollingOffsets,
+		limit:      min(maxUpdatesPerRequest, workers.QueueSize()),
+	}
+}
+
+// Run polls until ctx is done. Telegram answers right away while updates from the offset
+// on are unconfirmed, including the ones still being processed, so when an answer holds
+// nothing new Run waits for the offset to advance instead of asking again. A slow update
+// therefore holds back updates beyond the limit until it is done, while the ones before
+// them are processed.
+func (p *updatePoller) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		offset, advanced := p.offsets.Offset()
+		updates, err := p.getUpdates(tgbotapi.UpdateConfig{
+			Offset:         offset,
+			Limit:          p.limit,
+			Timeout:        60,
+			AllowedUpdates: allowedUpdates,
+		})
+		if err != nil {
+			LogWarn("Failed to get updates, retrying: %v", err)
+			sleepContext(ctx, pollingRetryDelay)
+			continue
+		}
+
+		received := 0
+		for _, update := range updates {
+			if !p.offsets.Received(update.UpdateID) {
+				continue
+			}
+			received++
+			// An update not queued before shutdown stays unconfirmed and is polled again
+			// on restart
+			if !p.submit(ctx, update) {
+				return
+			}
+		}
+		if len(updates) > 0 && received == 0 {
+			select {
+			case <-advanced:
+			case <-ctx.Done():
+			}
+		}
+	}
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+// loadPollingOffset returns the offset to resume polling from, i.e. the update after the
+// last one processed before the restart, or 0 if none was stored
+func loadPollingOffset() int {
+	value, err := getBotState(pollingOffsetStateKey())
+	if err != nil {
+		LogError("Failed to load polling offset, resuming from Telegram's: %v", err)
+		return 0
+	}
+	if value == "" {
+		return 0
+	}
+	lastUpdateID, err := strconv.Atoi(value)
+	if err != nil {
+		LogError("Invalid stored polling offset %q, resuming from Telegram's", value)
+		return 0
+	}
+	return lastUpdateID + 1
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestOffsetTrackerCommitsContiguousUpdates(t *testing.T) {
+	tracker := newOffsetTracker(10)
+	for _, id := range []int{10, 11, 12} {
+		if !tracker.Received(id) {
+			t.Fatalf("Received(%d) = false for a new update", id)
+		}
+	}
+	if tracker.Received(11) {
+		t.Error("Received(11) = true for an update already received")
+	}
+
+	// 11 and 12 are done before 10, so nothing is committed until 10 is
+	_, advanced := tracker.Offset()
+	tracker.Done(12)
+	tracker.Done(11)
+	if got, _ := tracker.Offset(); got != 10 {
+		t.Errorf("Offset() = %d with update 10 in progress, want 10", got)
+	}
+	select {
+	case <-advanced:
+		t.Error("offset reported advanced with update 10 in progress")
+	default:
+	}
+	tracker.Done(10)
+	if got, _ := tracker.Offset(); got != 13 {
+		t.Errorf("Offset() = %d with all updates done, want 13", got)
+	}
+	select {
+	case <-advanced:
+	default:
+		t.Error("offset not reported advanced after update 10 was done")
+	}
+}
+
+// fakeTelegram serves getUpdates from a fixed list of updates, forgetting the ones before
+// the requested offset like Telegram does
+type fakeTelegram struct {
+	mu       sync.Mutex
+	updates  []tgbotapi.Update
+	requests int
+	limits   []int
+}
+
+func (f *fakeTelegram) GetUpdates(config tgbotapi.UpdateConfig) ([]tgbotapi.Update, error) {
+	f.mu.Lock()
+	f.requests++
+	f.limits = append(f.limits, config.Limit)
+	var result []tgbotapi.Update
+	for _, update := range f.updates {
+		if update.UpdateID >= config.Offset && len(result) < config.Limit {
+			result = append(result, update)
+		}
+	}
+	f.mu.Unlock()
+	if len(result) == 0 {
+		// Stands in for a long poll that times out
+		time.Sleep(time.Millisecond)
+	}
+	return result, nil
+}
+
+func (f *fakeTelegram) Requests() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests
+}
+
+func chatUpdate(id int, chatID int64) tgbotapi.Update {
+	return tgbotapi.Update{UpdateID: id, Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: chatID}}}
+}
+
+func TestUpdatePollerDoesNotStallOnSlowChat(t *testing.T) {
+	const slowChat, otherChat = 1, 2
+	telegram := &fakeTelegram{updates: []tgbotapi.Update{
+		chatUpdate(1, slowChat),
+		chatUpdate(2, otherChat),
+		chatUpdate(3, otherChat),
+		chatUpdate(4, otherChat),
+		chatUpdate(5, otherChat),
+	}}
+
+	// The slow chat's update stays in progress until released, the others finish at once
+	offsets := newOffsetTracker(1)
+	release := make(chan struct{})
+	submitted := make(chan int, 10)
+	submit := func(ctx context.Context, update tgbotapi.Update) bool {
+		submitted <- update.UpdateID
+		if update.Message.Chat.ID == slowChat {
+			go func() {
+				<-release
+				offsets.Done(update.UpdateID)
+			}()
+			return true
+		}
+		offsets.Done(update.UpdateID)
+		return true
+	}
+
+	poller := &updatePoller{getUpdates: telegram.GetUpdates, submit: submit, offsets: offsets, limit: 3}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		poller.Run(ctx)
+		close(stopped)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	expectSubmitted := func(ids ...int) {
+		t.Helper()
+		for _, want := range ids {
+			select {
+			case got := <-submitted:
+				if got != want {
+					t.Fatalf("submitted update %d, want %d", got, want)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("update %d was not submitted", want)
+			}
+		}
+	}
+
+	// The other chat's updates within the limit are processed while the slow one is in progress
+	expectSubmitted(1, 2, 3)
+
+	// With nothing new to fetch until update 1 is done, polling waits instead of asking again
+	time.Sleep(50 * time.Millisecond)
+	if got := telegram.Requests(); got != 2 {
+		t.Errorf("getUpdates called %d times while waiting for the slow update, want 2", got)
+	}
+	select {
+	case id := <-submitted:
+		t.Fatalf("update %d submitted beyond the limit", id)
+	default:
+	}
+
+	close(release)
+	expectSubmitted(4, 5)
+
+	telegram.mu.Lock()
+	defer telegram.mu.Unlock()
+	for _, limit := range telegram.limits {
+		if limit != 3 {
+			t.Fatalf("getUpdates limit = %d, want 3", limit)
+		}
+	}
+}
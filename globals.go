@@ -1,13 +1,37 @@
 package main
 
 import (
-	"database/sql"
-
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
+// Relay modes for special chats: copies look like messages from the bot,
+// forwards keep the "Forwarded from" header
+const (
+	specialChatModeCopy    = "copy"
+	specialChatModeForward = "forward"
+)
+
+// telegramMaxMessageLength is the maximum length of a message text accepted by Telegram
+const telegramMaxMessageLength = 4096
+
 var (
-	db             *sql.DB
+	db             *instrumentedDB
 	bot            *tgbotapi.BotAPI
 	specialChatIDs []int64
+
+	// specialChatModes holds the relay mode for each special chat
+	specialChatModes map[int64]string
+
+	// relayOperatorIDs are the users allowed to relay messages with @sendto
+	relayOperatorIDs []int64
+
+	// mentionBatchSize limits how many members are mentioned in a single message,
+	// since Telegram stops notifying inline user links beyond a certain count
+	mentionBatchSize = 30
+
+	// cooldowns tracks recent mass mentions for flood protection
+	cooldowns cooldownStore = newMemoryCooldownStore()
+
+	// workers processes incoming updates, see startWorkerPool
+	workers *workerPool
 )
@@ -1,54 +1,113 @@
 package main
 
 import (
+	"database/sql"
 	"fmt"
 	"slices"
 	"strings"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
-func handleCommands(update tgbotapi.Update) {
+// maxDNDDuration caps how long /dnd can silence a member
+const maxDNDDuration = 30 * 24 * time.Hour
+
+func handleCommands(update tgbotapi.Update, ulog updateLog) {
 	chatID := update.Message.Chat.ID
 	cmd := update.Message.Command()
+	lang := messageLanguage(update.Message)
 
-	LogInfo("Received command %s from chat %d", cmd, chatID)
+	ulog.Info("Received command")
+	commandsTotal.Inc(commandLabel(cmd))
 
 	switch cmd {
-	case "start":
-		msg := tgbotapi.NewMessage(chatID, escapeMarkdownV2(startText()))
-		msg.ParseMode = "MarkdownV2"
-		if _, err := bot.Send(msg); err != nil {
-			LogError("Failed to send start message to chat %d: %v", chatID, err)
-		}
-
-	case "help":
-		msg := tgbotapi.NewMessage(chatID, escapeMarkdownV2(helpText()))
-		msg.ParseMode = "MarkdownV2"
-		if _, err := bot.Send(msg); err != nil {
-			LogError("Failed to send help message to chat %d: %v", chatID, err)
-		}
+	case "start", "help":
+		sendPersonaText(update.Message, cmd, ulog)
 
 	case "all":
 		// Get the message text from the command
 		message := update.Message.Text
 		if message == "" {
-			message = "No message provided."
+			message = tr(lang, "mention.no_message")
 		}
 
-		mentions := getMentions(chatID)
-		if mentions == "" {
-			mentions = "No members found to mention."
+		if !canMentionAll(update.Message, ulog) || !checkMentionCooldown(update.Message, ulog) {
+			return
 		}
-		msg := tgbotapi.NewMessage(chatID, escapeMarkdownV2(message)+"\n"+mentions)
-		msg.ParseMode = "MarkdownV2"
-		if _, err := bot.Send(msg); err != nil {
-			LogError("Failed to send all message to chat %d: %v", chatID, err)
+		if err := sendMentions(chatID, lang, message, getMentions(chatID, false)); err != nil {
+			ulog.Error("Failed to send all message to chat %d: %v", chatID, err)
+		}
+		recordMentionSummon(update.Message)
+
+	case "group":
+		handleGroupCommand(update, ulog)
+
+	case "mute_me", "unmute_me":
+		muted := cmd == "mute_me"
+		if err := setUserMuted(chatID, update.Message.From.ID, muted); err != nil {
+			ulog.Error("Failed to update mute for user %d in chat %d: %v", update.Message.From.ID, chatID, err)
+			sendText(chatID, tr(lang, "mute.failed"))
+			return
 		}
+		if muted {
+			sendText(chatID, tr(lang, "mute.on"))
+		} else {
+			sendText(chatID, tr(lang, "mute.off"))
+		}
+
+	case "dnd":
+		handleDNDCommand(update, ulog)
+
+	case "cooldown":
+		handleCooldownCommand(update, ulog)
+
+	case "mention_policy":
+		handleMentionPolicyCommand(update, ulog)
+
+	case "allow", "disallow", "allowlist":
+		handleAllowlistCommand(update, ulog)
+
+	case "schedule_poll":
+		handleSchedulePollCommand(update, ulog)
+
+	case "unschedule_poll":
+		handleUnschedulePollCommand(update, ulog)
+
+	case "schedules":
+		handleSchedulesCommand(update, ulog)
+
+	case "timezone":
+		handleTimezoneCommand(update, ulog)
+
+	case "attendance":
+		handleAttendanceCommand(update, ulog)
+
+	case "remind":
+		handleRemindCommand(update, ulog)
+
+	case "auto_remind":
+		handleAutoRemindCommand(update, ulog)
+
+	case "sync":
+		handleSyncCommand(update, ulog)
+
+	case "settings":
+		handleSettingsCommand(update, ulog)
+
+	case "lang":
+		handleLangCommand(update, ulog)
+
+	case "persona":
+		handlePersonaCommand(update, ulog)
+
+	case "template":
+		handleTemplateCommand(update, ulog)
 	}
 }
 
-func handleAtAllMention(update tgbotapi.Update) {
+// handleMentionTags pings everyone for @all, or the members of any named group tagged in the message
+func handleMentionTags(update tgbotapi.Update, ulog updateLog) {
 	// Ignore messages not from users (e.g., from the bot itself)
 	if update.Message == nil || update.Message.From.IsBot {
 		return
@@ -57,103 +116,405 @@ func handleAtAllMention(update tgbotapi.Update) {
 	chatID := update.Message.Chat.ID
 	text := update.Message.Text
 
-	if strings.Contains(strings.ToLower(text), "@all") {
-		LogInfo("Received @all mention in chat %d from user %d", chatID, update.Message.From.ID)
-		mentions := getMentions(chatID)
-		if mentions == "" {
-			mentions = "No members found to mention."
+	tags, urgent := detectMentionTags(text)
+	if len(tags) == 0 {
+		return
+	}
+
+	// Only admins may wake muted and do-not-disturb members
+	if urgent && !isChatAdmin(chatID, update.Message.From.ID, ulog) {
+		urgent = false
+	}
+
+	var groups []string
+	if slices.Contains(tags, "all") {
+		ulog.Info("Received @all mention in chat %d from user %d", chatID, update.Message.From.ID)
+	} else {
+		var err error
+		groups, err = filterMentionGroups(chatID, tags)
+		if err != nil {
+			ulog.Error("Failed to look up mention groups in chat %d: %v", chatID, err)
+			return
+		}
+		if len(groups) == 0 {
+			return
+		}
+		ulog.Info("Received @%s mention in chat %d from user %d", strings.Join(groups, ", @"), chatID, update.Message.From.ID)
+	}
+
+	if !canMentionAll(update.Message, ulog) || !checkMentionCooldown(update.Message, ulog) {
+		return
+	}
+
+	var mentions []string
+	if groups == nil {
+		mentions = getMentions(chatID, urgent)
+	} else {
+		mentions = getGroupMentions(chatID, groups, urgent)
+	}
+	if err := sendMentions(chatID, messageLanguage(update.Message), text, mentions); err != nil {
+		ulog.Error("Failed to send mention message to chat %d: %v", chatID, err)
+	}
+	recordMentionSummon(update.Message)
+}
+
+// handleGroupCommand manages named mention groups via /group <action> <name> [@users...]
+func handleGroupCommand(update tgbotapi.Update, ulog updateLog) {
+	chatID := update.Message.Chat.ID
+	lang := messageLanguage(update.Message)
+	args := strings.Fields(update.Message.CommandArguments())
+	if len(args) == 0 {
+		sendText(chatID, tr(lang, "group.usage"))
+		return
+	}
+
+	action := strings.ToLower(args[0])
+	if action == "list" {
+		groups, err := listMentionGroups(chatID)
+		if err != nil {
+			ulog.Error("Failed to list mention groups in chat %d: %v", chatID, err)
+			sendText(chatID, tr(lang, "group.list_failed"))
+			return
+		}
+		if len(groups) == 0 {
+			sendText(chatID, tr(lang, "group.none"))
+			return
+		}
+		names := make([]string, 0, len(groups))
+		for name := range groups {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		lines := make([]string, 0, len(names))
+		for _, name := range names {
+			lines = append(lines, tr(lang, "group.list_item", name, groups[name]))
+		}
+		sendText(chatID, tr(lang, "group.list", strings.Join(lines, "\n")))
+		return
+	}
+
+	if len(args) < 2 {
+		sendText(chatID, tr(lang, "group.action_usage", action))
+		return
+	}
+	groupName := normalizeGroupName(args[1])
+	if groupName == "" {
+		sendText(chatID, tr(lang, "group.invalid_name"))
+		return
+	}
+
+	// Members may join and leave groups themselves, everything else is up to the admins
+	adminAction := action == "create" || action == "delete" || action == "add" || action == "remove"
+	if adminAction && !isChatAdmin(chatID, update.Message.From.ID, ulog) {
+		sendText(chatID, tr(lang, "group.admins_only"))
+		return
+	}
+
+	switch action {
+	case "create":
+		created, err := createMentionGroup(chatID, groupName, update.Message.From.ID)
+		if err != nil {
+			ulog.Error("Failed to create group %s in chat %d: %v", groupName, chatID, err)
+			sendText(chatID, tr(lang, "group.create_failed"))
+			return
+		}
+		if !created {
+			sendText(chatID, tr(lang, "group.exists", groupName))
+			return
+		}
+		ulog.Info("Created group %s in chat %d", groupName, chatID)
+		sendText(chatID, tr(lang, "group.created", groupName, groupName, groupName))
+
+	case "delete":
+		deleted, err := deleteMentionGroup(chatID, groupName)
+		if err != nil {
+			ulog.Error("Failed to delete group %s in chat %d: %v", groupName, chatID, err)
+			sendText(chatID, tr(lang, "group.delete_failed"))
+			return
+		}
+		if !deleted {
+			sendText(chatID, tr(lang, "group.not_found", groupName))
+			return
+		}
+		ulog.Info("Deleted group %s in chat %d", groupName, chatID)
+		sendText(chatID, tr(lang, "group.deleted", groupName))
+
+	case "add", "remove", "join", "leave":
+		exists, err := mentionGroupExists(chatID, groupName)
+		if err != nil {
+			ulog.Error("Failed to look up group %s in chat %d: %v", groupName, chatID, err)
+			sendText(chatID, tr(lang, "group.update_failed"))
+			return
+		}
+		if !exists {
+			sendText(chatID, tr(lang, "group.create_first", groupName, groupName))
+			return
+		}
+
+		var userIDs []int64
+		var unknown []string
+		if action == "join" || action == "leave" {
+			userIDs = []int64{update.Message.From.ID}
+		} else {
+			userIDs, unknown = resolveGroupTargets(update, args[2:])
+			if len(userIDs) == 0 && len(unknown) == 0 {
+				sendText(chatID, tr(lang, "group.members_usage", action, groupName))
+				return
+			}
+		}
+
+		for _, userID := range userIDs {
+			if action == "add" || action == "join" {
+				err = addMentionGroupMember(chatID, groupName, userID)
+			} else {
+				err = removeMentionGroupMember(chatID, groupName, userID)
+			}
+			if err != nil {
+				ulog.Error("Failed to %s user %d for group %s in chat %d: %v", action, userID, groupName, chatID, err)
+				sendText(chatID, tr(lang, "group.update_failed"))
+				return
+			}
+		}
+		ulog.Info("Group %s in chat %d: %s %d users", groupName, chatID, action, len(userIDs))
+
+		reply := tr(lang, "group.updated", groupName, action, len(userIDs))
+		if len(unknown) > 0 {
+			reply += "\n" + tr(lang, "users.unknown", strings.Join(unknown, ", "))
+		}
+		sendText(chatID, reply)
+
+	default:
+		sendText(chatID, tr(lang, "group.unknown_action"))
+	}
+}
+
+// handleDNDCommand pauses mass mentions for the sender, e.g. /dnd 8h, or clears it with /dnd off
+func handleDNDCommand(update tgbotapi.Update, ulog updateLog) {
+	chatID := update.Message.Chat.ID
+	userID := update.Message.From.ID
+	lang := messageLanguage(update.Message)
+	arg := strings.TrimSpace(update.Message.CommandArguments())
+
+	if arg == "" {
+		sendText(chatID, tr(lang, "dnd.usage"))
+		return
+	}
+
+	if strings.EqualFold(arg, "off") {
+		if err := setUserDND(chatID, userID, nil); err != nil {
+			ulog.Error("Failed to clear dnd for user %d in chat %d: %v", userID, chatID, err)
+			sendText(chatID, tr(lang, "mute.failed"))
+			return
+		}
+		sendText(chatID, tr(lang, "dnd.off"))
+		return
+	}
+
+	duration, err := parseDuration(arg)
+	if err != nil || duration <= 0 || duration > maxDNDDuration {
+		sendText(chatID, tr(lang, "dnd.invalid"))
+		return
+	}
+
+	until := time.Now().Add(duration)
+	if err := setUserDND(chatID, userID, &until); err != nil {
+		ulog.Error("Failed to set dnd for user %d in chat %d: %v", userID, chatID, err)
+		sendText(chatID, tr(lang, "mute.failed"))
+		return
+	}
+	sendText(chatID, tr(lang, "dnd.until", until.UTC().Format("2006-01-02 15:04")))
+}
+
+// resolveGroupTargets maps @username arguments, text mentions and the replied-to user to user IDs
+func resolveGroupTargets(update tgbotapi.Update, args []string) ([]int64, []string) {
+	chatID := update.Message.Chat.ID
+	var userIDs []int64
+	var unknown []string
+
+	for _, entity := range update.Message.Entities {
+		if entity.Type == "text_mention" && entity.User != nil && !slices.Contains(userIDs, entity.User.ID) {
+			userIDs = append(userIDs, entity.User.ID)
 		}
-		msgText := escapeMarkdownV2(text) + "\n" + mentions
-		msg := tgbotapi.NewMessage(chatID, msgText)
-		msg.ParseMode = "MarkdownV2"
-		if _, err := bot.Send(msg); err != nil {
-			LogError("Failed to send @all mention message to chat %d: %v", chatID, err)
+	}
+
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "@") {
+			continue
+		}
+		userID, err := findUserIDByUsername(chatID, strings.TrimPrefix(arg, "@"))
+		if err != nil {
+			unknown = append(unknown, arg)
+			continue
+		}
+		if !slices.Contains(userIDs, userID) {
+			userIDs = append(userIDs, userID)
+		}
+	}
+
+	if reply := update.Message.ReplyToMessage; reply != nil && reply.From != nil && !reply.From.IsBot {
+		if !slices.Contains(userIDs, reply.From.ID) {
+			userIDs = append(userIDs, reply.From.ID)
 		}
 	}
+
+	return userIDs, unknown
 }
 
-func handleChatMemberUpdate(chatMember *tgbotapi.ChatMemberUpdated) {
+// handleChatMemberUpdate tracks members joining and leaving. The affected user is
+// NewChatMember.User; From is whoever made the change, e.g. the admin who kicked them.
+func handleChatMemberUpdate(chatMember *tgbotapi.ChatMemberUpdated, ulog updateLog) {
+	user := chatMember.NewChatMember.User
+	if user == nil {
+		return
+	}
 	chatID := chatMember.Chat.ID
-	userID := chatMember.From.ID
+	userID := user.ID
 	newStatus := chatMember.NewChatMember.Status
 
-	LogInfo("User %d changed status to %s in chat %d", userID, newStatus, chatID)
+	ulog.Info("User %d changed status to %s in chat %d (by user %d)", userID, newStatus, chatID, chatMember.From.ID)
+
+	// Save members as they join, including the ones who never speak
+	if !user.IsBot && isPresentStatus(chatMember.NewChatMember) {
+		saveUser(chatID, user)
+	}
 
 	switch newStatus {
 	case "left", "kicked":
 		err := deleteUser(chatID, userID)
 		if err != nil {
-			LogError("Failed to delete user %d from chat %d: %v", userID, chatID, err)
+			ulog.Error("Failed to delete user %d from chat %d: %v", userID, chatID, err)
 		}
 	}
 }
 
+// isSendToAttempt reports whether the message text, caption or poll question starts with @sendto
+func isSendToAttempt(message *tgbotapi.Message) bool {
+	if message.Poll != nil {
+		return strings.HasPrefix(message.Poll.Question, "@sendto")
+	}
+	return strings.HasPrefix(message.Text, "@sendto") || strings.HasPrefix(message.Caption, "@sendto")
+}
+
+// authorizeRelay allows @sendto only from relay operators in a private chat with the bot
+func authorizeRelay(message *tgbotapi.Message, ulog updateLog) bool {
+	chatID := message.Chat.ID
+	userID := message.From.ID
+
+	if !message.Chat.IsPrivate() {
+		ulog.Info("Rejected @sendto from user %d in non-private chat %d", userID, chatID)
+		sendText(chatID, tr(messageLanguage(message), "relay.private_only"))
+		return false
+	}
+	if !slices.Contains(relayOperatorIDs, userID) {
+		ulog.Info("Rejected @sendto from unauthorized user %d", userID)
+		sendText(chatID, tr(messageLanguage(message), "relay.unauthorized"))
+		return false
+	}
+	return true
+}
+
+// auditRelay records a relayed item, logging rather than failing if the audit write fails
+func auditRelay(message *tgbotapi.Message, targetChatID int64, kind, content string, sendErr error, ulog updateLog) {
+	route := "sendto"
+	if kind == "reply" {
+		route = "reply"
+	}
+	relayForwardsTotal.Inc(route, resultLabel(sendErr))
+
+	if err := saveRelayAudit(message.From.ID, message.Chat.ID, targetChatID, kind, content, sendErr); err != nil {
+		ulog.Error("Failed to audit relay from user %d to chat %d: %v", message.From.ID, targetChatID, err)
+	}
+}
+
 // Hidden command to send message to chat group by @sendto <chat_id> <message>
-func handleSendMessageToChatGroup(update tgbotapi.Update) {
+func handleSendMessageToChatGroup(update tgbotapi.Update, ulog updateLog) {
+	if !isSendToAttempt(update.Message) || !authorizeRelay(update.Message, ulog) {
+		return
+	}
+
+	message := update.Message
 	switch {
-	// Handle text messages
-	case update.Message.Text != "":
-		chatID, message := detectSendToMessage(update.Message.Text)
-		if chatID != 0 && message != "" {
-			msg := tgbotapi.NewMessage(chatID, escapeMarkdownV2(message))
-			msg.ParseMode = "MarkdownV2"
-			if _, err := bot.Send(msg); err != nil {
-				LogError("Failed to send message to chat %d: %v", chatID, err)
+	// Handle text messages, keeping the formatting of everything after the prefix
+	case message.Text != "":
+		chatID, text := detectSendToMessage(message.Text)
+		if chatID != 0 && text != "" {
+			msg := tgbotapi.NewMessage(chatID, text)
+			msg.Entities = trimEntities(message.Entities, utf16Len(message.Text)-utf16Len(text))
+			_, err := bot.Send(msg)
+			if err != nil {
+				ulog.Error("Failed to send message to chat %d: %v", chatID, err)
 			}
+			auditRelay(message, chatID, "text", text, err, ulog)
 		}
-	// Handle photo messages
-	case update.Message.Photo != nil && update.Message.Caption != "":
-		chatID, message := detectSendToMessage(update.Message.Caption)
-		if chatID != 0 {
-			photo := update.Message.Photo[len(update.Message.Photo)-1] // get highest resolution
-			msg := tgbotapi.NewPhoto(chatID, tgbotapi.FileID(photo.FileID))
-			if message != "" {
-				msg.Caption = escapeMarkdownV2(message)
-				msg.ParseMode = "MarkdownV2"
-			}
-			if _, err := bot.Send(msg); err != nil {
-				LogError("Failed to send message to chat %d: %v", chatID, err)
+	// Handle polls, which cannot be copied with a different question
+	case message.Poll != nil && !message.Poll.IsClosed && message.Poll.Question != "":
+		chatID, question := detectSendToMessage(message.Poll.Question)
+		if chatID != 0 && question != "" {
+			sent, err := bot.Send(rebuildPoll(chatID, message.Poll, question))
+			if err != nil {
+				ulog.Error("Failed to send poll to chat %d: %v", chatID, err)
+			} else {
+				recordPostedPoll(sent, time.Now().In(chatLocation(chatID)), 0)
 			}
+			auditRelay(message, chatID, "poll", question, err, ulog)
 		}
-	// Handle document messages
-	case update.Message.Document != nil && update.Message.Caption != "":
-		chatID, message := detectSendToMessage(update.Message.Caption)
+	// Handle any media with a caption by copying it with the prefix removed
+	case message.Caption != "":
+		chatID, caption := detectSendToMessage(message.Caption)
 		if chatID != 0 {
-			msg := tgbotapi.NewDocument(chatID, tgbotapi.FileID(update.Message.Document.FileID))
-			if message != "" {
-				msg.Caption = escapeMarkdownV2(message)
-				msg.ParseMode = "MarkdownV2"
-			}
-			if _, err := bot.Send(msg); err != nil {
-				LogError("Failed to send document to chat %d: %v", chatID, err)
-			}
-		}
-	// Handle poll messages
-	case update.Message.Poll != nil && !update.Message.Poll.IsClosed && update.Message.Poll.Question != "":
-		chatID, question := detectSendToMessage(update.Message.Poll.Question)
-		if chatID != 0 && question != "" {
-			options := make([]string, len(update.Message.Poll.Options))
-			for i, opt := range update.Message.Poll.Options {
-				options[i] = opt.Text
-			}
-			msg := tgbotapi.NewPoll(chatID, question, options...)
-			msg.IsAnonymous = update.Message.Poll.IsAnonymous
-			msg.AllowsMultipleAnswers = update.Message.Poll.AllowsMultipleAnswers
-			msg.Type = update.Message.Poll.Type
-			msg.Explanation = update.Message.Poll.Explanation
-			if _, err := bot.Send(msg); err != nil {
-				LogError("Failed to send poll to chat %d: %v", chatID, err)
+			_, err := copySendToMedia(chatID, message, caption, ulog)
+			if err != nil {
+				ulog.Error("Failed to send media to chat %d: %v", chatID, err)
 			}
+			auditRelay(message, chatID, mediaKind(message), caption, err, ulog)
 		}
 	}
 }
 
-func handleForwardMessageToSpecialChat(update tgbotapi.Update) {
+// copySendToMedia copies a captioned @sendto message into chatID with the prefix removed
+// from the caption, falling back to re-sending photos and documents by file ID
+func copySendToMedia(chatID int64, message *tgbotapi.Message, caption string, ulog updateLog) (int, error) {
+	entities := trimEntities(message.CaptionEntities, utf16Len(message.Caption)-utf16Len(caption))
+
+	config := tgbotapi.NewCopyMessage(chatID, message.Chat.ID, message.MessageID)
+	config.Caption = caption
+	config.CaptionEntities = entities
+	copied, err := bot.CopyMessage(config)
+	if err == nil {
+		return copied.MessageID, nil
+	}
+	ulog.Error("Failed to copy message %d to chat %d, re-sending it instead: %v", message.MessageID, chatID, err)
+
+	var fallback tgbotapi.Chattable
+	switch {
+	case message.Photo != nil:
+		photo := message.Photo[len(message.Photo)-1] // get highest resolution
+		msg := tgbotapi.NewPhoto(chatID, tgbotapi.FileID(photo.FileID))
+		msg.Caption = caption
+		msg.CaptionEntities = entities
+		fallback = msg
+	case message.Document != nil:
+		msg := tgbotapi.NewDocument(chatID, tgbotapi.FileID(message.Document.FileID))
+		msg.Caption = caption
+		msg.CaptionEntities = entities
+		fallback = msg
+	default:
+		return 0, err
+	}
+
+	sent, err := bot.Send(fallback)
+	if err != nil {
+		return 0, err
+	}
+	return sent.MessageID, nil
+}
+
+func handleForwardMessageToSpecialChat(update tgbotapi.Update, ulog updateLog) {
 	if len(specialChatIDs) == 0 {
 		return // No special chats configured
 	}
 
-	if strings.HasPrefix(update.Message.Text, "@sendto") {
+	if isSendToAttempt(update.Message) {
 		return // Ignore messages with @sendto
 	}
 
@@ -166,105 +527,180 @@ func handleForwardMessageToSpecialChat(update tgbotapi.Update) {
 		return // Ignore messages from the special chat ids
 	}
 
+	// Ignore chats whose admins turned forwarding off in /settings
+	if settings, err := getChatSettings(update.Message.Chat.ID); err != nil {
+		ulog.Error("Failed to load settings for chat %d: %v", update.Message.Chat.ID, err)
+	} else if !settings.ForwardToSpecial {
+		return
+	}
+
 	for _, specialChatID := range specialChatIDs {
-		sendInfoMessage(specialChatID, update)
-
-		// Forward the message based on its type
-		switch {
-		case update.Message.Text != "":
-			// Forward text message
-			msg := tgbotapi.NewMessage(specialChatID, update.Message.Text)
-			if _, err := bot.Send(msg); err != nil {
-				LogError("Failed to forward text message to chat %d: %v", specialChatID, err)
-			}
+		if infoMessageID := sendInfoMessage(specialChatID, update, ulog); infoMessageID != 0 {
+			saveForwardedMessage(specialChatID, infoMessageID, update.Message, ulog)
+		}
 
-		case update.Message.Photo != nil:
-			// Forward photo message
-			photo := update.Message.Photo[len(update.Message.Photo)-1] // get highest resolution
-			msg := tgbotapi.NewPhoto(specialChatID, tgbotapi.FileID(photo.FileID))
-			if update.Message.Caption != "" {
-				msg.Caption = update.Message.Caption
-			}
-			if _, err := bot.Send(msg); err != nil {
-				LogError("Failed to forward photo to chat %d: %v", specialChatID, err)
-			}
+		messageID, err := relayToSpecialChat(specialChatID, update.Message, ulog)
+		relayForwardsTotal.Inc("special_chat", resultLabel(err))
+		if err != nil {
+			ulog.Error("Failed to forward message %d to chat %d: %v", update.Message.MessageID, specialChatID, err)
+			continue
+		}
+		saveForwardedMessage(specialChatID, messageID, update.Message, ulog)
+	}
+}
 
-		case update.Message.Document != nil:
-			// Forward document message
-			msg := tgbotapi.NewDocument(specialChatID, tgbotapi.FileID(update.Message.Document.FileID))
-			if update.Message.Caption != "" {
-				msg.Caption = update.Message.Caption
-			}
-			if _, err := bot.Send(msg); err != nil {
-				LogError("Failed to forward document to chat %d: %v", specialChatID, err)
-			}
+// relayToSpecialChat copies (or forwards, if configured for the target) a message into a
+// special chat so every message type and entity survives. If Telegram refuses, the message
+// is rebuilt by type instead. It returns the ID of the message in the special chat.
+func relayToSpecialChat(specialChatID int64, message *tgbotapi.Message, ulog updateLog) (int, error) {
+	mode := specialChatModes[specialChatID]
+	if mode == specialChatModeForward {
+		sent, err := bot.Send(tgbotapi.NewForward(specialChatID, message.Chat.ID, message.MessageID))
+		if err == nil {
+			return sent.MessageID, nil
+		}
+		ulog.Error("Failed to forward message %d to chat %d, re-sending it instead: %v", message.MessageID, specialChatID, err)
+	} else {
+		copied, err := bot.CopyMessage(tgbotapi.NewCopyMessage(specialChatID, message.Chat.ID, message.MessageID))
+		if err == nil {
+			return copied.MessageID, nil
+		}
+		ulog.Error("Failed to copy message %d to chat %d, re-sending it instead: %v", message.MessageID, specialChatID, err)
+	}
+	return resendMessage(specialChatID, message)
+}
 
-		case update.Message.Video != nil:
-			// Forward video message
-			msg := tgbotapi.NewVideo(specialChatID, tgbotapi.FileID(update.Message.Video.FileID))
-			if update.Message.Caption != "" {
-				msg.Caption = update.Message.Caption
-			}
-			if _, err := bot.Send(msg); err != nil {
-				LogError("Failed to forward video to chat %d: %v", specialChatID, err)
-			}
+// resendMessage rebuilds a message by type in another chat. It is the fallback for when
+// copyMessage and forwardMessage fail, and only covers the common message types.
+func resendMessage(specialChatID int64, message *tgbotapi.Message) (int, error) {
+	var config tgbotapi.Chattable
+	switch {
+	case message.Text != "":
+		// Forward text message
+		msg := tgbotapi.NewMessage(specialChatID, message.Text)
+		msg.Entities = message.Entities
+		config = msg
 
-		case update.Message.Audio != nil:
-			// Forward audio message
-			msg := tgbotapi.NewAudio(specialChatID, tgbotapi.FileID(update.Message.Audio.FileID))
-			if update.Message.Caption != "" {
-				msg.Caption = update.Message.Caption
-			}
-			if _, err := bot.Send(msg); err != nil {
-				LogError("Failed to forward audio to chat %d: %v", specialChatID, err)
-			}
+	case message.Photo != nil:
+		// Forward photo message
+		photo := message.Photo[len(message.Photo)-1] // get highest resolution
+		msg := tgbotapi.NewPhoto(specialChatID, tgbotapi.FileID(photo.FileID))
+		msg.Caption = message.Caption
+		msg.CaptionEntities = message.CaptionEntities
+		config = msg
 
-		case update.Message.Voice != nil:
-			// Forward voice message
-			msg := tgbotapi.NewVoice(specialChatID, tgbotapi.FileID(update.Message.Voice.FileID))
-			if _, err := bot.Send(msg); err != nil {
-				LogError("Failed to forward voice to chat %d: %v", specialChatID, err)
-			}
+	case message.Document != nil:
+		// Forward document message
+		msg := tgbotapi.NewDocument(specialChatID, tgbotapi.FileID(message.Document.FileID))
+		msg.Caption = message.Caption
+		msg.CaptionEntities = message.CaptionEntities
+		config = msg
 
-		case update.Message.Sticker != nil:
-			// Forward sticker message
-			msg := tgbotapi.NewSticker(specialChatID, tgbotapi.FileID(update.Message.Sticker.FileID))
-			if _, err := bot.Send(msg); err != nil {
-				LogError("Failed to forward sticker to chat %d: %v", specialChatID, err)
-			}
+	case message.Video != nil:
+		// Forward video message
+		msg := tgbotapi.NewVideo(specialChatID, tgbotapi.FileID(message.Video.FileID))
+		msg.Caption = message.Caption
+		msg.CaptionEntities = message.CaptionEntities
+		config = msg
 
-		case update.Message.Poll != nil:
-			// Forward poll message
-			options := make([]string, len(update.Message.Poll.Options))
-			for i, opt := range update.Message.Poll.Options {
-				options[i] = opt.Text
-			}
-			msg := tgbotapi.NewPoll(specialChatID, update.Message.Poll.Question, options...)
-			msg.IsAnonymous = update.Message.Poll.IsAnonymous
-			msg.AllowsMultipleAnswers = update.Message.Poll.AllowsMultipleAnswers
-			msg.Type = update.Message.Poll.Type
-			msg.Explanation = update.Message.Poll.Explanation
-			if _, err := bot.Send(msg); err != nil {
-				LogError("Failed to forward poll to chat %d: %v", specialChatID, err)
-			}
+	case message.Audio != nil:
+		// Forward audio message
+		msg := tgbotapi.NewAudio(specialChatID, tgbotapi.FileID(message.Audio.FileID))
+		msg.Caption = message.Caption
+		msg.CaptionEntities = message.CaptionEntities
+		config = msg
 
-		default:
-			// Forward as generic message if type is not supported
-			msg := tgbotapi.NewMessage(specialChatID, "Unsupported message type forwarded")
-			if _, err := bot.Send(msg); err != nil {
-				LogError("Failed to forward unsupported message to chat %d: %v", specialChatID, err)
-			}
-		}
+	case message.Voice != nil:
+		// Forward voice message
+		config = tgbotapi.NewVoice(specialChatID, tgbotapi.FileID(message.Voice.FileID))
+
+	case message.Sticker != nil:
+		// Forward sticker message
+		config = tgbotapi.NewSticker(specialChatID, tgbotapi.FileID(message.Sticker.FileID))
+
+	case message.Poll != nil:
+		// Forward poll message
+		config = rebuildPoll(specialChatID, message.Poll, message.Poll.Question)
+
+	default:
+		// Forward as generic message if type is not supported
+		config = tgbotapi.NewMessage(specialChatID, "Unsupported message type forwarded")
+	}
+
+	sent, err := bot.Send(config)
+	if err != nil {
+		return 0, err
+	}
+	return sent.MessageID, nil
+}
+
+// rebuildPoll creates a poll in chatID with the same options and settings as poll
+func rebuildPoll(chatID int64, poll *tgbotapi.Poll, question string) tgbotapi.SendPollConfig {
+	options := make([]string, len(poll.Options))
+	for i, opt := range poll.Options {
+		options[i] = opt.Text
+	}
+	msg := tgbotapi.NewPoll(chatID, question, options...)
+	msg.IsAnonymous = poll.IsAnonymous
+	msg.AllowsMultipleAnswers = poll.AllowsMultipleAnswers
+	msg.Type = poll.Type
+	msg.Explanation = poll.Explanation
+	return msg
+}
+
+// saveForwardedMessage remembers where a message copied into a special chat came from,
+// so replies to it can be routed back
+func saveForwardedMessage(specialChatID int64, specialMessageID int, origin *tgbotapi.Message, ulog updateLog) {
+	if err := saveRelayMessage(specialChatID, specialMessageID, origin.Chat.ID, origin.MessageID); err != nil {
+		ulog.Error("Failed to save relay mapping for message %d in chat %d: %v", specialMessageID, specialChatID, err)
+	}
+}
+
+// handleSpecialChatReply sends a reply made in a special chat back to the chat the
+// original message came from, as a reply to that message. It reports whether the
+// message was a relay reply.
+func handleSpecialChatReply(update tgbotapi.Update, ulog updateLog) bool {
+	message := update.Message
+	if message.ReplyToMessage == nil || !slices.Contains(specialChatIDs, message.Chat.ID) {
+		return false
+	}
+	if message.From == nil || message.From.IsBot {
+		return false
 	}
+
+	originChatID, originMessageID, err := findRelayOrigin(message.Chat.ID, message.ReplyToMessage.MessageID)
+	if err == sql.ErrNoRows {
+		return false
+	}
+	if err != nil {
+		ulog.Error("Failed to look up relay origin for message %d in chat %d: %v", message.ReplyToMessage.MessageID, message.Chat.ID, err)
+		return false
+	}
+
+	reply := tgbotapi.NewCopyMessage(originChatID, message.Chat.ID, message.MessageID)
+	reply.ReplyToMessageID = originMessageID
+	reply.AllowSendingWithoutReply = true
+	if _, err := bot.CopyMessage(reply); err != nil {
+		ulog.Error("Failed to relay reply from chat %d to chat %d: %v", message.Chat.ID, originChatID, err)
+		sendText(message.Chat.ID, tr(messageLanguage(message), "relay.reply_failed"))
+		return true
+	}
+
+	ulog.Info("Relayed reply from user %d in chat %d to chat %d", message.From.ID, message.Chat.ID, originChatID)
+	auditRelay(message, originChatID, "reply", message.Text, nil, ulog)
+	return true
 }
 
-// Add <ChatID>-<UserID>-<Username> to the message text
-func sendInfoMessage(chatID int64, update tgbotapi.Update) {
+// Add <ChatID>-<UserID>-<Username> to the message text and return the sent message ID
+func sendInfoMessage(chatID int64, update tgbotapi.Update, ulog updateLog) int {
 	message := fmt.Sprintf("<%d>-<%s>-<%d>-<@%s>", update.Message.Chat.ID, update.Message.Chat.Title, update.Message.From.ID, update.Message.From.UserName)
 
 	msg := tgbotapi.NewMessage(chatID, escapeMarkdownV2(message))
 	msg.ParseMode = "MarkdownV2"
-	if _, err := bot.Send(msg); err != nil {
-		LogError("Failed to send info message to chat %d: %v", chatID, err)
+	sent, err := bot.Send(msg)
+	if err != nil {
+		ulog.Error("Failed to send info message to chat %d: %v", chatID, err)
+		return 0
 	}
+	return sent.MessageID
 }
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// readinessCheckTimeout bounds each dependency check of /readyz
+const readinessCheckTimeout = 3 * time.Second
+
+// telegramSuccessMaxAge is how recent the last successful Telegram call must be in polling
+// mode. Long polls return at least every 60 seconds, so anything older means trouble.
+const telegramSuccessMaxAge = 3 * time.Minute
+
+// telegramCheckCacheTTL is how long the result of the Telegram check is reused, so frequent
+// probes don't each call Telegram
+const telegramCheckCacheTTL = 5 * time.Second
+
+// componentStatus is the state of one dependency in the /readyz response
+type componentStatus struct {
+	Status  string                 `json:"status"`
+	Error   string                 `json:"error,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// registerStatusHandlers adds the health and metrics endpoints to a server's mux
+func registerStatusHandlers(mux *http.ServeMux, webhookMode bool) {
+	mux.HandleFunc("/metrics", metricsHandler)
+	mux.HandleFunc("/healthz", healthzHandler)
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		readyzHandler(w, r, webhookMode)
+	})
+}
+
+// healthzHandler reports that the process is alive and serving HTTP
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readyzHandler checks the database and Telegram and answers 503 if either is down
+func readyzHandler(w http.ResponseWriter, r *http.Request, webhookMode bool) {
+	components := map[string]componentStatus{
+		"database": checkDatabase(r.Context()),
+		"telegram": cachedTelegramStatus(webhookMode),
+	}
+
+	status, code := "ok", http.StatusOK
+	for _, component := range components {
+		if component.Status != "ok" {
+			status, code = "unavailable", http.StatusServiceUnavailable
+		}
+	}
+	writeJSON(w, code, map[string]interface{}{"status": status, "components": components})
+}
+
+func checkDatabase(ctx context.Context) componentStatus {
+	ctx, cancel := context.WithTimeout(ctx, readinessCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	if err := db.PingContext(ctx); err != nil {
+		return componentStatus{Status: "down", Error: err.Error()}
+	}
+	return componentStatus{Status: "ok", Details: map[string]interface{}{
+		"latency_ms": time.Since(start).Milliseconds(),
+	}}
+}
+
+// telegramCheck holds the last Telegram check. Its lock is held while checking, so
+// concurrent probes wait for one check instead of starting their own.
+var telegramCheck struct {
+	sync.Mutex
+	status    componentStatus
+	checkedAt time.Time
+}
+
+func cachedTelegramStatus(webhookMode bool) componentStatus {
+	telegramCheck.Lock()
+	defer telegramCheck.Unlock()
+	if time.Since(telegramCheck.checkedAt) < telegramCheckCacheTTL {
+		return telegramCheck.status
+	}
+	telegramCheck.status = checkTelegramWithTimeout(webhookMode)
+	telegramCheck.checkedAt = time.Now()
+	return telegramCheck.status
+}
+
+// checkTelegramWithTimeout gives up on the Telegram check after readinessCheckTimeout. The
+// Bot API calls don't take a context, so a hung call finishes in the background, bounded
+// by the bot's HTTP client timeout.
+func checkTelegramWithTimeout(webhookMode bool) componentStatus {
+	result := make(chan componentStatus, 1)
+	go func() {
+		result <- checkTelegram(webhookMode)
+	}()
+
+	timer := time.NewTimer(readinessCheckTimeout)
+	defer timer.Stop()
+	select {
+	case status := <-result:
+		return status
+	case <-timer.C:
+		return componentStatus{Status: "down", Error: fmt.Sprintf("no response from Telegram within %s", readinessCheckTimeout)}
+	}
+}
+
+// checkTelegram asks Telegram for the webhook status in webhook mode. In polling mode a
+// recent successful call is enough; otherwise getMe tells whether the token still works.
+func checkTelegram(webhookMode bool) componentStatus {
+	if webhookMode {
+		info, err := bot.GetWebhookInfo()
+		if err != nil {
+			LogError("Telegram readiness check failed: %v", err)
+			return componentStatus{Status: "down", Error: telegramError(err)}
+		}
+		details := map[string]interface{}{
+			"pending_update_count": info.PendingUpdateCount,
+		}
+		if info.LastErrorDate != 0 {
+			details["last_error_date"] = time.Unix(int64(info.LastErrorDate), 0).UTC()
+			details["last_error_message"] = info.LastErrorMessage
+		}
+		if !info.IsSet() {
+			return componentStatus{Status: "down", Error: "webhook is not set", Details: details}
+		}
+		return componentStatus{Status: "ok", Details: details}
+	}
+
+	details := map[string]interface{}{}
+	if last := lastTelegramSuccess.Load(); last != 0 {
+		lastSuccess := time.Unix(last, 0)
+		details["last_success"] = lastSuccess.UTC()
+		if time.Since(lastSuccess) < telegramSuccessMaxAge {
+			return componentStatus{Status: "ok", Details: details}
+		}
+	}
+	if _, err := bot.GetMe(); err != nil {
+		LogError("Telegram readiness check failed: %v", err)
+		return componentStatus{Status: "down", Error: telegramError(err), Details: details}
+	}
+	return componentStatus{Status: "ok", Details: details}
+}
+
+// telegramError describes a failed Bot API call for the public /readyz response. Network
+// errors include the request URL and with it the bot token, so only Telegram's own errors
+// are shown; callers log the full error.
+func telegramError(err error) string {
+	var apiErr *tgbotapi.Error
+	if errors.As(err, &apiErr) {
+		return fmt.Sprintf("%d: %s", apiErr.Code, apiErr.Message)
+	}
+	return "network error"
+}
+
+func writeJSON(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		LogError("Failed to write JSON response: %v", err)
+	}
+}
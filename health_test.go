@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestTelegramErrorHidesToken(t *testing.T) {
+	const token = "123456:secret-token"
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "network error",
+			err: &url.Error{
+				Op:  "Post",
+				URL: "https://api.telegram.org/bot" + token + "/getMe",
+				Err: errors.New("connection refused"),
+			},
+			want: "network error",
+		},
+		{
+			name: "API error",
+			err:  &tgbotapi.Error{Code: 401, Message: "Unauthorized"},
+			want: "401: Unauthorized",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := telegramError(tt.err)
+			if got != tt.want || strings.Contains(got, token) {
+				t.Errorf("telegramError() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,676 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// defaultLanguage is used when neither the chat nor the user has a supported language
+const defaultLanguage = "en"
+
+// supportedLanguages are the languages with a message catalog, in display order
+var supportedLanguages = []string{"en", "vi"}
+
+// languageNames are shown when picking a language, in the language itself
+var languageNames = map[string]string{
+	"en": "English",
+	"vi": "Tiếng Việt",
+}
+
+// messages is the catalog of user-facing texts by language and key.
+// Texts are plain and escaped for MarkdownV2 when sent.
+var messages = map[string]map[string]string{
+	"en": {
+		"persona.werewolf.start": "🌕 *Awooo! I am the Alpha Wolf of @{bot_name}!*\n\n" +
+			"As the Alpha of this pack, I'll help gather all the wolves for our nightly hunts. Here's how to summon the pack:\n\n" +
+			"*Pack Commands:*\n" +
+			"• /start - Hear the Alpha's howl\n" +
+			"• /help - Learn the ways of the pack\n" +
+			"• /all - Summon all wolves to the hunt\n" +
+			"• /group - Manage named packs like @mods or @weekend\n" +
+			"• /mute_me, /unmute_me, /dnd - Rest from pack summons\n\n" +
+			"*Pack Features:*\n" +
+			"• Type `@all` in any message to call the pack\n" +
+			"• I track all wolves in our territory\n" +
+			"• Wolves who leave are removed from the pack\n\n" +
+			"*Note:* To summon the pack, I need to be an Alpha in the group. Grant me the necessary permissions to lead the hunt.\n\n",
+		"persona.werewolf.help": "🌕 *Pack Commands Guide*\n\n" +
+			"*How to Summon the Pack:*\n" +
+			"• Use /all to call all wolves to the hunt\n" +
+			"• Type @all in any message to gather the pack\n" +
+			"• Type @<group> to call only that pack, e.g. @mods\n\n" +
+			"*Named Packs:*\n" +
+			"• /group create <name> - Alphas form a new pack\n" +
+			"• /group delete <name> - Alphas disband a pack\n" +
+			"• /group add <name> @user ... - Alphas add wolves to a pack\n" +
+			"• /group remove <name> @user ... - Alphas remove wolves from a pack\n" +
+			"• /group join <name> - Join a pack yourself\n" +
+			"• /group leave <name> - Leave a pack\n" +
+			"• /group list - Show all packs\n\n" +
+			"*Resting Wolves:*\n" +
+			"• /mute_me - Stop being summoned by @all and packs\n" +
+			"• /unmute_me - Be summoned again\n" +
+			"• /dnd 8h - Rest for a while (/dnd off to wake up)\n" +
+			"• Alphas can type @all! to wake every wolf for urgent hunts\n\n" +
+			"*Pack Discipline:*\n" +
+			"• The pack can only be summoned once per cooldown, Alphas are exempt\n" +
+			"• /cooldown - Show the cooldowns\n" +
+			"• /cooldown chat 2m or /cooldown user 10m - Alphas change them\n" +
+			"• /mention_policy everyone|admins|allowlist - Alphas choose who may summon\n" +
+			"• /allow @user, /disallow @user, /allowlist - Manage the allow-list\n\n" +
+			"*Nightly Hunts:*\n" +
+			"• /schedule_poll 19:30 \"Who's playing tonight?\" - Alphas post a poll every day\n" +
+			"• Add options with | Yes | No, the default is Yes / No / Maybe\n" +
+			"• /schedules - List the scheduled polls\n" +
+			"• /unschedule_poll <id> - Remove a scheduled poll\n" +
+			"• /timezone Asia/Ho_Chi_Minh - Set the pack's timezone\n" +
+			"• /attendance - See who answered today's poll\n" +
+			"• /attendance week or /attendance month - See who joined the hunts\n" +
+			"• /remind - Call the wolves who haven't answered today's poll\n" +
+			"• /auto_remind <id> 21:00 30 - Remind them 30 minutes before a 21:00 hunt\n\n" +
+			"*Alpha's Notes:*\n" +
+			"• I track all wolves in our territory\n" +
+			"• Wolves who leave are removed from the pack\n" +
+			"• Wolves who join, vote or speak are added to the pack\n" +
+			"• /sync - Alphas see how many wolves I know\n" +
+			"• /settings - Alphas open the pack's settings panel\n" +
+			"• /lang vi - Alphas choose the pack's language\n" +
+			"• /persona, /template - Alphas change how I greet the pack\n" +
+			"• I need to be an Alpha to summon the pack\n\n" +
+			"*Need Help?*\n" +
+			"Use /start to hear the Alpha's howl again.\n\n",
+
+		"persona.neutral.start": "👋 *Hi, I'm @{bot_name}!*\n\n" +
+			"I help {chat_title} reach everyone at once. Type @all in any message, or use /all, and I'll mention every member I know.\n\n" +
+			"*Commands:*\n{commands}\n\n" +
+			"*Note:* I need to be an admin of the group to see who joins and leaves.\n",
+		"persona.neutral.help": "ℹ️ *How to use @{bot_name}*\n\n" +
+			"*Mentions:*\n" +
+			"• @all or /all - Mention every member\n" +
+			"• @<group> - Mention only that group, e.g. @mods\n" +
+			"• @all! - Admins mention muted members too\n\n" +
+			"*Commands:*\n{commands}\n\n" +
+			"Members are added when they send a message, vote in a poll or join, and removed when they leave.\n",
+		"persona.office.start": "📋 *Good day, {chat_title} team!*\n\n" +
+			"I'm @{bot_name}, your meeting assistant. I can notify the whole team, post daily check-in polls and track who responded.\n\n" +
+			"*Available commands:*\n{commands}\n\n" +
+			"*Note:* Please make me an admin of the group so I can keep the team list up to date.\n",
+		"persona.office.help": "📋 *Team Assistant Guide*\n\n" +
+			"*Notifying the team:*\n" +
+			"• @all or /all - Notify everyone\n" +
+			"• @<group> - Notify one team, e.g. @design\n" +
+			"• @all! - Admins also notify colleagues on leave or in focus mode\n\n" +
+			"*Check-ins:*\n" +
+			"• /schedule_poll 09:00 \"Joining the stand-up?\" - Post a daily check-in\n" +
+			"• /attendance - See who responded\n" +
+			"• /remind - Follow up with colleagues who haven't responded\n\n" +
+			"*All commands:*\n{commands}\n",
+
+		// Personas and templates
+		"persona.werewolf.name": "🐺 Werewolf",
+		"persona.neutral.name":  "👋 Neutral",
+		"persona.office.name":   "📋 Office",
+		"persona.show":          "Persona: %s\n\nAdmins can change it with /persona werewolf|neutral|office.",
+		"persona.admins_only":   "Only chat admins can change the persona.",
+		"persona.usage":         "Usage: /persona werewolf|neutral|office",
+		"persona.updated":       "Persona is now %s.",
+		"template.custom":       "Custom /%s text is set. /template %s reset restores the persona's text.",
+		"template.admins_only":  "Only chat admins can change the templates.",
+		"template.usage":        "Usage: /template start|help <text>, or reply to a message with /template start|help. /template start|help reset restores the persona's text.\n\nVariables: {chat_title}, {bot_name}, {user_name}, {commands}",
+		"template.too_long":     "Templates may be at most %d characters.",
+		"template.updated":      "The /%s text is updated. Send /%s to see it.",
+		"template.reset":        "The /%s text is back to the persona's text.",
+
+		// Mentions
+		"mention.no_members":     "No members found to mention.",
+		"mention.partial":        "Only %d of %d mention messages could be delivered, some members were not pinged.",
+		"mention.no_message":     "No message provided.",
+		"mention.throttled":      "The pack was summoned %s ago by %s. Try again in %s.",
+		"mention.denied_admins":  "Sorry, only chat admins can summon the whole pack here.",
+		"mention.denied_allowed": "Sorry, only admins and allow-listed members can summon the whole pack here.",
+
+		// Mute and do not disturb
+		"mute.failed": "Failed to update your mention preference.",
+		"mute.on":     "You will no longer be pinged by @all or group mentions. Use /unmute_me to undo.",
+		"mute.off":    "You will be pinged by @all and group mentions again.",
+		"dnd.usage":   "Usage: /dnd <duration>, e.g. /dnd 8h, /dnd 30m or /dnd 2d. Use /dnd off to end it early.",
+		"dnd.off":     "Do not disturb is off.",
+		"dnd.invalid": "Please give a duration between 1m and 30d, e.g. /dnd 8h.",
+		"dnd.until":   "Do not disturb until %s UTC.",
+
+		// Mention groups
+		"group.usage":          "Usage: /group create|delete|add|remove|join|leave <name> [@user ...], or /group list",
+		"group.list_failed":    "Failed to list groups.",
+		"group.none":           "No groups defined yet. Create one with /group create <name>.",
+		"group.list":           "Groups in this chat:\n%s",
+		"group.list_item":      "• @%s (%d members)",
+		"group.action_usage":   "Usage: /group %s <name>",
+		"group.invalid_name":   "Group names may only contain letters, digits and underscores (max 32), and \"all\" is reserved.",
+		"group.create_failed":  "Failed to create group.",
+		"group.exists":         "Group @%s already exists.",
+		"group.created":        "Group @%s created. Add members with /group add %s @user or /group join %s.",
+		"group.delete_failed":  "Failed to delete group.",
+		"group.not_found":      "Group @%s does not exist.",
+		"group.deleted":        "Group @%s deleted.",
+		"group.update_failed":  "Failed to update group.",
+		"group.create_first":   "Group @%s does not exist. Create it with /group create %s.",
+		"group.members_usage":  "Usage: /group %s %s @user ... (or reply to a user's message)",
+		"group.updated":        "Group @%s updated (%s: %d).",
+		"group.unknown_action": "Unknown action. Use create, delete, add, remove, join, leave or list.",
+		"group.admins_only":    "Only chat admins can create, delete or change groups. Use /group join|leave <name> to join or leave one yourself.",
+		"users.unknown":        "Unknown users (they need to send a message first): %s",
+
+		// Cooldowns
+		"cooldown.show":        "Mention cooldowns:\n• Chat: %s\n• Per user: %s\n\nAdmins can change them with /cooldown chat <duration> or /cooldown user <duration> (0 disables).",
+		"cooldown.admins_only": "Only chat admins can change the cooldowns.",
+		"cooldown.usage":       "Usage: /cooldown chat <duration> or /cooldown user <duration>, e.g. /cooldown chat 2m",
+		"cooldown.invalid":     "Please give a duration between 0 and 24h, e.g. 30s, 2m or 1h.",
+		"cooldown.updated":     "The %s cooldown is now %s.",
+
+		// Settings
+		"settings.load_failed":   "Failed to load chat settings.",
+		"settings.save_failed":   "Failed to save chat settings.",
+		"settings.admins_only":   "Only chat admins can change the settings.",
+		"settings.saved":         "Saved.",
+		"settings.failed":        "Failed to save the setting.",
+		"settings.close":         "Close",
+		"settings.on":            "On",
+		"settings.off":           "Off",
+		"settings.auto":          "Auto",
+		"settings.everyone":      "Everyone",
+		"settings.admins":        "Admins",
+		"settings.allowlist":     "Allow-list",
+		"settings.auto_language": "Auto (each member's Telegram language)",
+		"settings.text": "⚙️ Chat settings\n\n" +
+			"Mention policy: %s\n" +
+			"Chat cooldown: %s\n" +
+			"User cooldown: %s\n" +
+			"Language: %s\n" +
+			"Forward to special chats: %s\n" +
+			"Scheduled polls: %s\n" +
+			"Timezone: %s\n" +
+			"Persona: %s\n\n" +
+			"Use /timezone for other timezones, /schedules to manage polls and /template for custom texts.",
+
+		// Mention policy and allow-list
+		"policy.show":           "Mention policy: %s\n\nAdmins can change it with /mention_policy everyone|admins|allowlist.",
+		"policy.admins_only":    "Only chat admins can change the mention policy.",
+		"policy.usage":          "Usage: /mention_policy everyone|admins|allowlist",
+		"policy.updated":        "Mention policy is now %s.",
+		"allowlist.load_failed": "Failed to load the allow-list.",
+		"allowlist.empty":       "The allow-list is empty.",
+		"allowlist.list":        "Allow-listed members:\n• %s",
+		"allowlist.admins_only": "Only chat admins can change the allow-list.",
+		"allowlist.usage":       "Usage: /%s @user ... (or reply to a user's message)",
+		"allowlist.failed":      "Failed to update the allow-list.",
+		"allowlist.updated":     "Allow-list updated (%s: %d).",
+
+		// Relay
+		"relay.private_only": "This command is only available in a private chat with the bot.",
+		"relay.unauthorized": "You are not authorized to relay messages.",
+		"relay.reply_failed": "Failed to deliver the reply to the original chat.",
+
+		// Members
+		"sync.admins_only":  "Only chat admins can sync the member list.",
+		"sync.count_failed": "Failed to count the known members.",
+		"sync.known":        "I know %d members of this chat.",
+		"sync.known_of":     "I know %d of %d members of this chat.",
+		"sync.hint":         "Telegram doesn't let bots list members, so the others are added as soon as they send a message, vote in a poll or join.",
+
+		// Scheduled polls
+		"schedule.admins_only":   "Only chat admins can schedule polls.",
+		"schedule.usage":         "Usage: /schedule_poll HH:MM \"Question\" [| Option 1 | Option 2 ...]",
+		"schedule.options":       "Polls need between 2 and 10 options.",
+		"schedule.failed":        "Failed to schedule the poll.",
+		"schedule.created":       "Scheduled poll #%d every day at %s (%s): %s",
+		"unschedule.admins_only": "Only chat admins can remove scheduled polls.",
+		"unschedule.usage":       "Usage: /unschedule_poll <id>. Use /schedules to see the IDs.",
+		"unschedule.failed":      "Failed to remove the scheduled poll.",
+		"schedule.not_found":     "There is no scheduled poll #%d in this chat.",
+		"unschedule.done":        "Scheduled poll #%d removed.",
+		"schedules.failed":       "Failed to load the scheduled polls.",
+		"schedules.none":         "No polls are scheduled. Admins can add one with /schedule_poll HH:MM \"Question\".",
+		"schedules.list":         "Scheduled polls (%s):\n%s",
+		"schedules.item":         "#%d at %s: %s (%s)",
+		"schedules.reminder":     ", reminder %dm before %s",
+		"timezone.show":          "Timezone: %s\n\nAdmins can change it with /timezone <IANA name>, e.g. /timezone Asia/Ho_Chi_Minh.",
+		"timezone.admins_only":   "Only chat admins can change the timezone.",
+		"timezone.unknown":       "Unknown timezone %q. Use an IANA name such as Europe/Berlin or Asia/Ho_Chi_Minh.",
+		"timezone.updated":       "Timezone is now %s.",
+		"remind.admins_only":     "Only chat admins can configure reminders.",
+		"remind.usage":           "Usage: /auto_remind <poll id> <game HH:MM> <minutes before>, or /auto_remind <poll id> off",
+		"remind.minutes":         "Minutes before must be between 0 and 720.",
+		"remind.failed":          "Failed to save the reminder.",
+		"remind.off":             "Automatic reminder for poll #%d is off.",
+		"remind.on":              "Members who haven't answered poll #%d will be reminded %d minutes before %s.",
+
+		// Attendance
+		"attendance.usage":           "Usage: /attendance [week|month]",
+		"attendance.no_poll":         "No poll was posted today.",
+		"attendance.poll_failed":     "Failed to load today's poll.",
+		"attendance.votes_failed":    "Failed to load today's votes.",
+		"attendance.votes":           "%s (%d votes)",
+		"attendance.option":          "\n%s (%d): %s",
+		"attendance.summary_failed":  "Failed to load the attendance summary.",
+		"attendance.week":            "the last 7 days",
+		"attendance.month":           "the last 30 days",
+		"attendance.no_polls":        "No polls were posted in %s.",
+		"attendance.summary":         "Attendance over %s (%d polls), yes / other / no answer:",
+		"attendance.user":            "user %d",
+		"reminder.everyone_answered": "Everyone has answered: %s",
+		"reminder.text":              "Reminder, please answer today's poll: %s",
+
+		// Language
+		"lang.show":        "Language: %s\n\nAdmins can change it with /lang en|vi, or /lang auto to follow each member's Telegram language.",
+		"lang.admins_only": "Only chat admins can change the language.",
+		"lang.usage":       "Usage: /lang en|vi|auto",
+		"lang.updated":     "Language is now %s.",
+
+		// Bot menu
+		"command.start":           "Show welcome message",
+		"command.help":            "Show help message",
+		"command.all":             "Mention all members",
+		"command.group":           "Manage named mention groups",
+		"command.mute_me":         "Stop being pinged by mass mentions",
+		"command.unmute_me":       "Be pinged by mass mentions again",
+		"command.dnd":             "Pause mass mentions for a while, e.g. /dnd 8h",
+		"command.cooldown":        "Show or change mass mention cooldowns",
+		"command.mention_policy":  "Show or change who may mention everyone",
+		"command.allowlist":       "Show members allowed to mention everyone",
+		"command.schedule_poll":   "Schedule a daily poll, e.g. /schedule_poll 19:30 Who's playing?",
+		"command.unschedule_poll": "Remove a scheduled poll",
+		"command.schedules":       "List the scheduled polls",
+		"command.timezone":        "Show or set the chat timezone",
+		"command.attendance":      "Show today's votes, or /attendance week|month",
+		"command.remind":          "Mention members who haven't answered today's poll",
+		"command.auto_remind":     "Remind non-voters automatically before game time",
+		"command.sync":            "Seed members from admins and show how many are known",
+		"command.settings":        "Open the chat settings panel",
+		"command.lang":            "Show or set the chat language",
+		"command.persona":         "Show or set the bot persona",
+		"command.template":        "Set a custom /start or /help text",
+	},
+	"vi": {
+		"persona.werewolf.start": "🌕 *Awooo! Ta là Sói Đầu Đàn của @{bot_name}!*\n\n" +
+			"Là thủ lĩnh của bầy, ta sẽ giúp tập hợp tất cả các chú sói cho những cuộc săn đêm. Đây là cách triệu tập bầy:\n\n" +
+			"*Lệnh của bầy:*\n" +
+			"• /start - Nghe tiếng hú của Sói Đầu Đàn\n" +
+			"• /help - Học luật lệ của bầy\n" +
+			"• /all - Triệu tập tất cả sói đi săn\n" +
+			"• /group - Quản lý các nhóm như @mods hay @weekend\n" +
+			"• /mute_me, /unmute_me, /dnd - Nghỉ ngơi, không bị triệu tập\n\n" +
+			"*Tính năng của bầy:*\n" +
+			"• Gõ `@all` trong bất kỳ tin nhắn nào để gọi cả bầy\n" +
+			"• Ta theo dõi mọi chú sói trong lãnh thổ\n" +
+			"• Sói rời nhóm sẽ bị xóa khỏi bầy\n\n" +
+			"*Lưu ý:* Để triệu tập bầy, ta cần là quản trị viên của nhóm. Hãy cấp cho ta quyền cần thiết để dẫn dắt cuộc săn.\n\n",
+		"persona.werewolf.help": "🌕 *Hướng dẫn lệnh của bầy*\n\n" +
+			"*Cách triệu tập bầy:*\n" +
+			"• Dùng /all để gọi tất cả sói đi săn\n" +
+			"• Gõ @all trong bất kỳ tin nhắn nào để tập hợp bầy\n" +
+			"• Gõ @<nhóm> để chỉ gọi nhóm đó, ví dụ @mods\n\n" +
+			"*Các nhóm:*\n" +
+			"• /group create <tên> - Quản trị viên lập nhóm mới\n" +
+			"• /group delete <tên> - Quản trị viên giải tán nhóm\n" +
+			"• /group add <tên> @user ... - Quản trị viên thêm sói vào nhóm\n" +
+			"• /group remove <tên> @user ... - Quản trị viên đưa sói ra khỏi nhóm\n" +
+			"• /group join <tên> - Tự tham gia nhóm\n" +
+			"• /group leave <tên> - Rời nhóm\n" +
+			"• /group list - Xem tất cả các nhóm\n\n" +
+			"*Sói nghỉ ngơi:*\n" +
+			"• /mute_me - Không bị gọi bởi @all và các nhóm\n" +
+			"• /unmute_me - Được gọi trở lại\n" +
+			"• /dnd 8h - Nghỉ một lúc (/dnd off để thức dậy)\n" +
+			"• Quản trị viên có thể gõ @all! để đánh thức mọi chú sói khi khẩn cấp\n\n" +
+			"*Kỷ luật của bầy:*\n" +
+			"• Bầy chỉ được triệu tập một lần mỗi khoảng chờ, quản trị viên được miễn\n" +
+			"• /cooldown - Xem thời gian chờ\n" +
+			"• /cooldown chat 2m hoặc /cooldown user 10m - Quản trị viên thay đổi\n" +
+			"• /mention_policy everyone|admins|allowlist - Quản trị viên chọn ai được triệu tập\n" +
+			"• /allow @user, /disallow @user, /allowlist - Quản lý danh sách cho phép\n\n" +
+			"*Những cuộc săn đêm:*\n" +
+			"• /schedule_poll 19:30 \"Tối nay ai chơi?\" - Quản trị viên đăng bình chọn mỗi ngày\n" +
+			"• Thêm lựa chọn với | Có | Không, mặc định là Yes / No / Maybe\n" +
+			"• /schedules - Xem các bình chọn đã lên lịch\n" +
+			"• /unschedule_poll <id> - Xóa một bình chọn đã lên lịch\n" +
+			"• /timezone Asia/Ho_Chi_Minh - Đặt múi giờ của bầy\n" +
+			"• /attendance - Xem ai đã trả lời bình chọn hôm nay\n" +
+			"• /attendance week hoặc /attendance month - Xem ai đã tham gia săn\n" +
+			"• /remind - Gọi những chú sói chưa trả lời bình chọn hôm nay\n" +
+			"• /auto_remind <id> 21:00 30 - Nhắc họ 30 phút trước cuộc săn lúc 21:00\n\n" +
+			"*Ghi chú của Sói Đầu Đàn:*\n" +
+			"• Ta theo dõi mọi chú sói trong lãnh thổ\n" +
+			"• Sói rời nhóm sẽ bị xóa khỏi bầy\n" +
+			"• Sói tham gia, bình chọn hoặc nhắn tin sẽ được thêm vào bầy\n" +
+			"• /sync - Quản trị viên xem ta biết bao nhiêu chú sói\n" +
+			"• /settings - Quản trị viên mở bảng cài đặt của bầy\n" +
+			"• /lang en - Quản trị viên chọn ngôn ngữ của bầy\n" +
+			"• /persona, /template - Quản trị viên đổi cách ta chào bầy\n" +
+			"• Ta cần là quản trị viên để triệu tập bầy\n\n" +
+			"*Cần trợ giúp?*\n" +
+			"Dùng /start để nghe lại tiếng hú của Sói Đầu Đàn.\n\n",
+
+		"persona.neutral.start": "👋 *Xin chào, mình là @{bot_name}!*\n\n" +
+			"Mình giúp {chat_title} gọi mọi người cùng lúc. Gõ @all trong bất kỳ tin nhắn nào, hoặc dùng /all, mình sẽ nhắc tên mọi thành viên mình biết.\n\n" +
+			"*Các lệnh:*\n{commands}\n\n" +
+			"*Lưu ý:* Mình cần là quản trị viên của nhóm để biết ai tham gia và rời nhóm.\n",
+		"persona.neutral.help": "ℹ️ *Cách dùng @{bot_name}*\n\n" +
+			"*Nhắc tên:*\n" +
+			"• @all hoặc /all - Nhắc tất cả thành viên\n" +
+			"• @<nhóm> - Chỉ nhắc nhóm đó, ví dụ @mods\n" +
+			"• @all! - Quản trị viên nhắc cả những người đã tắt thông báo\n\n" +
+			"*Các lệnh:*\n{commands}\n\n" +
+			"Thành viên được thêm khi họ nhắn tin, bình chọn hoặc tham gia nhóm, và bị xóa khi rời nhóm.\n",
+		"persona.office.start": "📋 *Chào cả nhóm {chat_title}!*\n\n" +
+			"Tôi là @{bot_name}, trợ lý cuộc họp của nhóm. Tôi có thể thông báo cho cả nhóm, đăng bình chọn điểm danh hằng ngày và theo dõi ai đã phản hồi.\n\n" +
+			"*Các lệnh:*\n{commands}\n\n" +
+			"*Lưu ý:* Hãy cấp quyền quản trị viên cho tôi để tôi cập nhật danh sách thành viên.\n",
+		"persona.office.help": "📋 *Hướng dẫn trợ lý nhóm*\n\n" +
+			"*Thông báo cho nhóm:*\n" +
+			"• @all hoặc /all - Thông báo cho mọi người\n" +
+			"• @<nhóm> - Thông báo cho một nhóm, ví dụ @design\n" +
+			"• @all! - Quản trị viên thông báo cả những người đang nghỉ hoặc tập trung\n\n" +
+			"*Điểm danh:*\n" +
+			"• /schedule_poll 09:00 \"Tham gia họp đầu ngày?\" - Đăng điểm danh hằng ngày\n" +
+			"• /attendance - Xem ai đã phản hồi\n" +
+			"• /remind - Nhắc những người chưa phản hồi\n\n" +
+			"*Tất cả các lệnh:*\n{commands}\n",
+
+		// Personas and templates
+		"persona.werewolf.name": "🐺 Ma sói",
+		"persona.neutral.name":  "👋 Trung tính",
+		"persona.office.name":   "📋 Văn phòng",
+		"persona.show":          "Phong cách: %s\n\nQuản trị viên có thể thay đổi với /persona werewolf|neutral|office.",
+		"persona.admins_only":   "Chỉ quản trị viên mới được thay đổi phong cách.",
+		"persona.usage":         "Cách dùng: /persona werewolf|neutral|office",
+		"persona.updated":       "Phong cách hiện là %s.",
+		"template.custom":       "Đang dùng nội dung /%s tùy chỉnh. /template %s reset để dùng lại nội dung của phong cách.",
+		"template.admins_only":  "Chỉ quản trị viên mới được thay đổi mẫu nội dung.",
+		"template.usage":        "Cách dùng: /template start|help <nội dung>, hoặc trả lời một tin nhắn bằng /template start|help. /template start|help reset để dùng lại nội dung của phong cách.\n\nBiến: {chat_title}, {bot_name}, {user_name}, {commands}",
+		"template.too_long":     "Mẫu nội dung tối đa %d ký tự.",
+		"template.updated":      "Đã cập nhật nội dung /%s. Gửi /%s để xem.",
+		"template.reset":        "Nội dung /%s đã trở về nội dung của phong cách.",
+
+		// Mentions
+		"mention.no_members":     "Không tìm thấy thành viên nào để nhắc.",
+		"mention.partial":        "Chỉ gửi được %d trên %d tin nhắn, một số thành viên chưa được nhắc.",
+		"mention.no_message":     "Không có nội dung.",
+		"mention.throttled":      "Bầy vừa được %[2]s triệu tập %[1]s trước. Hãy thử lại sau %[3]s.",
+		"mention.denied_admins":  "Xin lỗi, chỉ quản trị viên mới được triệu tập cả bầy ở đây.",
+		"mention.denied_allowed": "Xin lỗi, chỉ quản trị viên và thành viên trong danh sách cho phép mới được triệu tập cả bầy ở đây.",
+
+		// Mute and do not disturb
+		"mute.failed": "Không thể cập nhật tùy chọn nhắc tên của bạn.",
+		"mute.on":     "Bạn sẽ không còn bị nhắc bởi @all hoặc các nhóm. Dùng /unmute_me để hoàn tác.",
+		"mute.off":    "Bạn sẽ được nhắc bởi @all và các nhóm trở lại.",
+		"dnd.usage":   "Cách dùng: /dnd <thời gian>, ví dụ /dnd 8h, /dnd 30m hoặc /dnd 2d. Dùng /dnd off để kết thúc sớm.",
+		"dnd.off":     "Đã tắt chế độ không làm phiền.",
+		"dnd.invalid": "Vui lòng nhập thời gian từ 1m đến 30d, ví dụ /dnd 8h.",
+		"dnd.until":   "Không làm phiền đến %s UTC.",
+
+		// Mention groups
+		"group.usage":          "Cách dùng: /group create|delete|add|remove|join|leave <tên> [@user ...], hoặc /group list",
+		"group.list_failed":    "Không thể liệt kê các nhóm.",
+		"group.none":           "Chưa có nhóm nào. Tạo nhóm với /group create <tên>.",
+		"group.list":           "Các nhóm trong cuộc trò chuyện:\n%s",
+		"group.list_item":      "• @%s (%d thành viên)",
+		"group.action_usage":   "Cách dùng: /group %s <tên>",
+		"group.invalid_name":   "Tên nhóm chỉ gồm chữ cái, chữ số và dấu gạch dưới (tối đa 32 ký tự), và \"all\" đã được dành riêng.",
+		"group.create_failed":  "Không thể tạo nhóm.",
+		"group.exists":         "Nhóm @%s đã tồn tại.",
+		"group.created":        "Đã tạo nhóm @%s. Thêm thành viên với /group add %s @user hoặc /group join %s.",
+		"group.delete_failed":  "Không thể xóa nhóm.",
+		"group.not_found":      "Nhóm @%s không tồn tại.",
+		"group.deleted":        "Đã xóa nhóm @%s.",
+		"group.update_failed":  "Không thể cập nhật nhóm.",
+		"group.create_first":   "Nhóm @%s không tồn tại. Tạo nhóm với /group create %s.",
+		"group.members_usage":  "Cách dùng: /group %s %s @user ... (hoặc trả lời tin nhắn của người đó)",
+		"group.updated":        "Đã cập nhật nhóm @%s (%s: %d).",
+		"group.unknown_action": "Thao tác không hợp lệ. Dùng create, delete, add, remove, join, leave hoặc list.",
+		"group.admins_only":    "Chỉ quản trị viên mới được tạo, xóa hoặc thay đổi nhóm. Dùng /group join|leave <tên> để tự tham gia hoặc rời nhóm.",
+		"users.unknown":        "Không rõ người dùng (họ cần nhắn tin trước): %s",
+
+		// Cooldowns
+		"cooldown.show":        "Thời gian chờ giữa các lần nhắc:\n• Cả nhóm: %s\n• Mỗi người: %s\n\nQuản trị viên có thể thay đổi với /cooldown chat <thời gian> hoặc /cooldown user <thời gian> (0 để tắt).",
+		"cooldown.admins_only": "Chỉ quản trị viên mới được thay đổi thời gian chờ.",
+		"cooldown.usage":       "Cách dùng: /cooldown chat <thời gian> hoặc /cooldown user <thời gian>, ví dụ /cooldown chat 2m",
+		"cooldown.invalid":     "Vui lòng nhập thời gian từ 0 đến 24h, ví dụ 30s, 2m hoặc 1h.",
+		"cooldown.updated":     "Thời gian chờ %s hiện là %s.",
+
+		// Settings
+		"settings.load_failed":   "Không thể tải cài đặt.",
+		"settings.save_failed":   "Không thể lưu cài đặt.",
+		"settings.admins_only":   "Chỉ quản trị viên mới được thay đổi cài đặt.",
+		"settings.saved":         "Đã lưu.",
+		"settings.failed":        "Không thể lưu cài đặt này.",
+		"settings.close":         "Đóng",
+		"settings.on":            "Bật",
+		"settings.off":           "Tắt",
+		"settings.auto":          "Tự động",
+		"settings.everyone":      "Mọi người",
+		"settings.admins":        "Quản trị viên",
+		"settings.allowlist":     "Danh sách cho phép",
+		"settings.auto_language": "Tự động (theo ngôn ngữ Telegram của từng thành viên)",
+		"settings.text": "⚙️ Cài đặt\n\n" +
+			"Ai được nhắc cả nhóm: %s\n" +
+			"Thời gian chờ cả nhóm: %s\n" +
+			"Thời gian chờ mỗi người: %s\n" +
+			"Ngôn ngữ: %s\n" +
+			"Chuyển tiếp đến nhóm đặc biệt: %s\n" +
+			"Bình chọn theo lịch: %s\n" +
+			"Múi giờ: %s\n" +
+			"Phong cách: %s\n\n" +
+			"Dùng /timezone cho múi giờ khác, /schedules để quản lý bình chọn và /template cho nội dung tùy chỉnh.",
+
+		// Mention policy and allow-list
+		"policy.show":           "Ai được nhắc cả nhóm: %s\n\nQuản trị viên có thể thay đổi với /mention_policy everyone|admins|allowlist.",
+		"policy.admins_only":    "Chỉ quản trị viên mới được thay đổi quy tắc nhắc tên.",
+		"policy.usage":          "Cách dùng: /mention_policy everyone|admins|allowlist",
+		"policy.updated":        "Quy tắc nhắc tên hiện là %s.",
+		"allowlist.load_failed": "Không thể tải danh sách cho phép.",
+		"allowlist.empty":       "Danh sách cho phép đang trống.",
+		"allowlist.list":        "Thành viên trong danh sách cho phép:\n• %s",
+		"allowlist.admins_only": "Chỉ quản trị viên mới được thay đổi danh sách cho phép.",
+		"allowlist.usage":       "Cách dùng: /%s @user ... (hoặc trả lời tin nhắn của người đó)",
+		"allowlist.failed":      "Không thể cập nhật danh sách cho phép.",
+		"allowlist.updated":     "Đã cập nhật danh sách cho phép (%s: %d).",
+
+		// Relay
+		"relay.private_only": "Lệnh này chỉ dùng được trong cuộc trò chuyện riêng với bot.",
+		"relay.unauthorized": "Bạn không có quyền chuyển tiếp tin nhắn.",
+		"relay.reply_failed": "Không thể gửi câu trả lời đến cuộc trò chuyện gốc.",
+
+		// Members
+		"sync.admins_only":  "Chỉ quản trị viên mới được đồng bộ danh sách thành viên.",
+		"sync.count_failed": "Không thể đếm số thành viên đã biết.",
+		"sync.known":        "Ta biết %d thành viên của nhóm này.",
+		"sync.known_of":     "Ta biết %d trên %d thành viên của nhóm này.",
+		"sync.hint":         "Telegram không cho bot xem danh sách thành viên, nên những người khác sẽ được thêm khi họ nhắn tin, bình chọn hoặc tham gia nhóm.",
+
+		// Scheduled polls
+		"schedule.admins_only":   "Chỉ quản trị viên mới được lên lịch bình chọn.",
+		"schedule.usage":         "Cách dùng: /schedule_poll HH:MM \"Câu hỏi\" [| Lựa chọn 1 | Lựa chọn 2 ...]",
+		"schedule.options":       "Bình chọn cần từ 2 đến 10 lựa chọn.",
+		"schedule.failed":        "Không thể lên lịch bình chọn.",
+		"schedule.created":       "Đã lên lịch bình chọn #%d lúc %s mỗi ngày (%s): %s",
+		"unschedule.admins_only": "Chỉ quản trị viên mới được xóa bình chọn đã lên lịch.",
+		"unschedule.usage":       "Cách dùng: /unschedule_poll <id>. Dùng /schedules để xem các ID.",
+		"unschedule.failed":      "Không thể xóa bình chọn đã lên lịch.",
+		"schedule.not_found":     "Không có bình chọn #%d trong nhóm này.",
+		"unschedule.done":        "Đã xóa bình chọn #%d.",
+		"schedules.failed":       "Không thể tải các bình chọn đã lên lịch.",
+		"schedules.none":         "Chưa có bình chọn nào được lên lịch. Quản trị viên có thể thêm với /schedule_poll HH:MM \"Câu hỏi\".",
+		"schedules.list":         "Các bình chọn đã lên lịch (%s):\n%s",
+		"schedules.item":         "#%d lúc %s: %s (%s)",
+		"schedules.reminder":     ", nhắc %d phút trước %s",
+		"timezone.show":          "Múi giờ: %s\n\nQuản trị viên có thể thay đổi với /timezone <tên IANA>, ví dụ /timezone Asia/Ho_Chi_Minh.",
+		"timezone.admins_only":   "Chỉ quản trị viên mới được thay đổi múi giờ.",
+		"timezone.unknown":       "Không rõ múi giờ %q. Hãy dùng tên IANA như Europe/Berlin hoặc Asia/Ho_Chi_Minh.",
+		"timezone.updated":       "Múi giờ hiện là %s.",
+		"remind.admins_only":     "Chỉ quản trị viên mới được cài đặt nhắc nhở.",
+		"remind.usage":           "Cách dùng: /auto_remind <id bình chọn> <giờ chơi HH:MM> <số phút trước>, hoặc /auto_remind <id bình chọn> off",
+		"remind.minutes":         "Số phút trước phải từ 0 đến 720.",
+		"remind.failed":          "Không thể lưu nhắc nhở.",
+		"remind.off":             "Đã tắt nhắc nhở tự động cho bình chọn #%d.",
+		"remind.on":              "Những thành viên chưa trả lời bình chọn #%d sẽ được nhắc %d phút trước %s.",
+
+		// Attendance
+		"attendance.usage":           "Cách dùng: /attendance [week|month]",
+		"attendance.no_poll":         "Hôm nay chưa có bình chọn nào.",
+		"attendance.poll_failed":     "Không thể tải bình chọn hôm nay.",
+		"attendance.votes_failed":    "Không thể tải các lượt bình chọn hôm nay.",
+		"attendance.votes":           "%s (%d lượt bình chọn)",
+		"attendance.option":          "\n%s (%d): %s",
+		"attendance.summary_failed":  "Không thể tải thống kê tham gia.",
+		"attendance.week":            "7 ngày qua",
+		"attendance.month":           "30 ngày qua",
+		"attendance.no_polls":        "Không có bình chọn nào trong %s.",
+		"attendance.summary":         "Thống kê tham gia trong %s (%d bình chọn), có / khác / chưa trả lời:",
+		"attendance.user":            "người dùng %d",
+		"reminder.everyone_answered": "Mọi người đã trả lời: %s",
+		"reminder.text":              "Nhắc nhở, hãy trả lời bình chọn hôm nay: %s",
+
+		// Language
+		"lang.show":        "Ngôn ngữ: %s\n\nQuản trị viên có thể thay đổi với /lang en|vi, hoặc /lang auto để theo ngôn ngữ Telegram của từng thành viên.",
+		"lang.admins_only": "Chỉ quản trị viên mới được thay đổi ngôn ngữ.",
+		"lang.usage":       "Cách dùng: /lang en|vi|auto",
+		"lang.updated":     "Ngôn ngữ hiện là %s.",
+
+		// Bot menu
+		"command.start":           "Hiển thị lời chào",
+		"command.help":            "Hiển thị hướng dẫn",
+		"command.all":             "Nhắc tất cả thành viên",
+		"command.group":           "Quản lý các nhóm nhắc tên",
+		"command.mute_me":         "Không bị nhắc khi gọi cả nhóm",
+		"command.unmute_me":       "Được nhắc khi gọi cả nhóm trở lại",
+		"command.dnd":             "Tạm dừng nhắc tên một lúc, ví dụ /dnd 8h",
+		"command.cooldown":        "Xem hoặc đổi thời gian chờ giữa các lần nhắc",
+		"command.mention_policy":  "Xem hoặc đổi ai được nhắc cả nhóm",
+		"command.allowlist":       "Xem thành viên được phép nhắc cả nhóm",
+		"command.schedule_poll":   "Lên lịch bình chọn hằng ngày, ví dụ /schedule_poll 19:30 Ai chơi?",
+		"command.unschedule_poll": "Xóa một bình chọn đã lên lịch",
+		"command.schedules":       "Xem các bình chọn đã lên lịch",
+		"command.timezone":        "Xem hoặc đặt múi giờ",
+		"command.attendance":      "Xem bình chọn hôm nay, hoặc /attendance week|month",
+		"command.remind":          "Nhắc những người chưa trả lời bình chọn hôm nay",
+		"command.auto_remind":     "Tự động nhắc người chưa bình chọn trước giờ chơi",
+		"command.sync":            "Thêm thành viên từ quản trị viên và xem số đã biết",
+		"command.settings":        "Mở bảng cài đặt",
+		"command.lang":            "Xem hoặc đổi ngôn ngữ",
+		"command.persona":         "Xem hoặc đổi phong cách của bot",
+		"command.template":        "Đặt nội dung /start hoặc /help tùy chỉnh",
+	},
+}
+
+// commandNames are the commands shown in the bot menu, described by "command.<name>" messages
+var commandNames = []string{
+	"start", "help", "all", "group", "mute_me", "unmute_me", "dnd", "cooldown", "mention_policy",
+	"allowlist", "schedule_poll", "unschedule_poll", "schedules", "timezone", "attendance", "remind",
+	"auto_remind", "sync", "settings", "lang", "persona", "template",
+}
+
+// botCommands returns the bot menu with descriptions in lang
+func botCommands(lang string) []tgbotapi.BotCommand {
+	commands := make([]tgbotapi.BotCommand, 0, len(commandNames))
+	for _, name := range commandNames {
+		commands = append(commands, tgbotapi.BotCommand{Command: name, Description: tr(lang, "command."+name)})
+	}
+	return commands
+}
+
+// tr returns the text for key in lang, falling back to the default language and then
+// to the key itself. Arguments are applied with fmt.Sprintf.
+func tr(lang, key string, args ...interface{}) string {
+	text, ok := messages[lang][key]
+	if !ok {
+		text, ok = messages[defaultLanguage][key]
+	}
+	if !ok {
+		LogError("Missing message %q", key)
+		text = key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(text, args...)
+	}
+	return text
+}
+
+// resolveLanguage picks the language for a chat: the chat's configured language,
+// otherwise the user's Telegram language if supported, otherwise the default
+func resolveLanguage(chatID int64, user *tgbotapi.User) string {
+	settings, err := getChatSettings(chatID)
+	if err != nil {
+		LogError("Failed to load settings for chat %d: %v", chatID, err)
+	} else if settings.Language != "" {
+		return settings.Language
+	}
+
+	if user != nil {
+		// language_code is an IETF tag such as "vi" or "en-US"
+		code, _, _ := strings.Cut(strings.ToLower(user.LanguageCode), "-")
+		if slices.Contains(supportedLanguages, code) {
+			return code
+		}
+	}
+	return defaultLanguage
+}
+
+// messageLanguage picks the language for replies to a message
+func messageLanguage(message *tgbotapi.Message) string {
+	return resolveLanguage(message.Chat.ID, message.From)
+}
+
+// chatLanguage picks the language for messages not replying to anyone, e.g. scheduled ones
+func chatLanguage(chatID int64) string {
+	return resolveLanguage(chatID, nil)
+}
+
+// handleLangCommand shows or sets the chat language, e.g. /lang vi
+func handleLangCommand(update tgbotapi.Update, ulog updateLog) {
+	chatID := update.Message.Chat.ID
+	lang := messageLanguage(update.Message)
+	arg := strings.ToLower(strings.TrimSpace(update.Message.CommandArguments()))
+
+	if arg == "" {
+		settings, err := getChatSettings(chatID)
+		if err != nil {
+			ulog.Error("Failed to load settings for chat %d: %v", chatID, err)
+			sendText(chatID, tr(lang, "settings.load_failed"))
+			return
+		}
+		name := tr(lang, "settings.auto_language")
+		if settings.Language != "" {
+			name = languageNames[settings.Language]
+		}
+		sendText(chatID, tr(lang, "lang.show", name))
+		return
+	}
+
+	if !isChatAdmin(chatID, update.Message.From.ID, ulog) {
+		sendText(chatID, tr(lang, "lang.admins_only"))
+		return
+	}
+
+	if arg == "auto" {
+		arg = ""
+	} else if !slices.Contains(supportedLanguages, arg) {
+		sendText(chatID, tr(lang, "lang.usage"))
+		return
+	}
+
+	if err := setChatSetting(chatID, "language", arg); err != nil {
+		ulog.Error("Failed to save language for chat %d: %v", chatID, err)
+		sendText(chatID, tr(lang, "settings.save_failed"))
+		return
+	}
+
+	lang = resolveLanguage(chatID, update.Message.From)
+	name := tr(lang, "settings.auto_language")
+	if arg != "" {
+		name = languageNames[arg]
+	}
+	sendText(chatID, tr(lang, "lang.updated", name))
+}
@@ -1,56 +1,296 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// levelFatal is logged right before the process exits
+const levelFatal = slog.LevelError + 4
+
+// Defaults for the log files, overridable with LOG_DIR and LOG_RETENTION_DAYS
+const (
+	defaultLogDir           = "logs"
+	defaultLogRetentionDays = 14
 )
 
 var (
-	infoLogger  *log.Logger
-	errorLogger *log.Logger
+	logger    = slog.New(slog.NewTextHandler(os.Stderr, nil)) // replaced by initLogger
+	logWriter *rotatingWriter
 )
 
+// initLogger sets up the logger from the environment:
+//   - LOG_LEVEL: debug, info (default), warn or error
+//   - LOG_FORMAT: text (default) or json
+//   - LOG_OUTPUT: file (default) writes to daily log files and stderr, stdout writes to stdout only
+//   - LOG_DIR and LOG_RETENTION_DAYS: where the daily files go and how many days are kept
 func initLogger() {
-	// Create logs directory if it doesn't exist
-	if err := os.MkdirAll("logs", 0755); err != nil {
-		log.Fatal("Failed to create logs directory:", err)
-	}
-
-	// Create or open log file with date in filename
-	currentTime := time.Now().Format("2006-01-02")
-	logFile, err := os.OpenFile(
-		fmt.Sprintf("logs/tagbot-%s.log", currentTime),
-		os.O_APPEND|os.O_CREATE|os.O_WRONLY,
-		0644,
-	)
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(envOrDefault("LOG_LEVEL", "info"))); err != nil {
+		log.Fatalf("Invalid LOG_LEVEL: %v", err)
+	}
+
+	var out io.Writer
+	switch output := strings.ToLower(envOrDefault("LOG_OUTPUT", "file")); output {
+	case "stdout":
+		out = os.Stdout
+	case "file":
+		retentionDays := defaultLogRetentionDays
+		if value := os.Getenv("LOG_RETENTION_DAYS"); value != "" {
+			days, err := strconv.Atoi(value)
+			if err != nil || days < 0 {
+				log.Fatalf("Invalid LOG_RETENTION_DAYS: %s", value)
+			}
+			retentionDays = days
+		}
+		writer, err := newRotatingWriter(envOrDefault("LOG_DIR", defaultLogDir), retentionDays)
+		if err != nil {
+			log.Fatal("Failed to open log file:", err)
+		}
+		logWriter = writer
+		out = io.MultiWriter(writer, os.Stderr)
+	default:
+		log.Fatalf("Invalid LOG_OUTPUT: %s", output)
+	}
+
+	options := &slog.HandlerOptions{AddSource: true, Level: level, ReplaceAttr: nameFatalLevel}
+	var handler slog.Handler
+	switch format := strings.ToLower(envOrDefault("LOG_FORMAT", "text")); format {
+	case "text":
+		handler = slog.NewTextHandler(out, options)
+	case "json":
+		handler = slog.NewJSONHandler(out, options)
+	default:
+		log.Fatalf("Invalid LOG_FORMAT: %s", format)
+	}
+	logger = slog.New(handler)
+
+	// The Telegram library logs failed long polls itself
+	if err := tgbotapi.SetLogger(slog.NewLogLogger(handler, slog.LevelWarn)); err != nil {
+		LogError("Failed to set Telegram library logger: %v", err)
+	}
+}
+
+// closeLogger flushes and closes the log file. Later messages only go to stderr.
+func closeLogger() {
+	if logWriter == nil {
+		return
+	}
+	logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	if err := logWriter.Close(); err != nil {
+		log.Printf("ERROR: Failed to close log file: %v", err)
+	}
+}
+
+// nameFatalLevel prints levelFatal as FATAL instead of ERROR+4
+func nameFatalLevel(groups []string, attr slog.Attr) slog.Attr {
+	if attr.Key == slog.LevelKey && len(groups) == 0 {
+		if level, ok := attr.Value.Any().(slog.Level); ok && level == levelFatal {
+			attr.Value = slog.StringValue("FATAL")
+		}
+	}
+	return attr
+}
+
+func envOrDefault(name, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// rotatingWriter writes to logs/tagbot-<date>.log, switching to a new file when the
+// day changes and deleting files older than the retention period
+type rotatingWriter struct {
+	dir           string
+	retentionDays int // 0 keeps all files
+
+	mu   sync.Mutex
+	file *os.File
+	day  string
+}
+
+func newRotatingWriter(dir string, retentionDays int) (*rotatingWriter, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	w := &rotatingWriter{dir: dir, retentionDays: retentionDays}
+	if err := w.rotate(time.Now()); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *rotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+	if now := time.Now(); now.Format(time.DateOnly) != w.day {
+		if err := w.rotate(now); err != nil {
+			// Keep writing to the old file rather than losing messages
+			fmt.Fprintf(os.Stderr, "Failed to rotate log file: %v\n", err)
+		}
+	}
+	return w.file.Write(p)
+}
+
+// rotate opens the file for the day of now and removes expired ones. Called with mu held.
+func (w *rotatingWriter) rotate(now time.Time) error {
+	day := now.Format(time.DateOnly)
+	file, err := os.OpenFile(filepath.Join(w.dir, "tagbot-"+day+".log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
-		log.Fatal("Failed to open log file:", err)
+		return err
+	}
+	if w.file != nil {
+		w.file.Close()
 	}
+	w.file, w.day = file, day
+	w.removeExpired(now)
+	return nil
+}
 
-	// Initialize loggers
-	infoLogger = log.New(logFile, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
-	errorLogger = log.New(logFile, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
+// removeExpired deletes the log files of days past the retention period
+func (w *rotatingWriter) removeExpired(now time.Time) {
+	if w.retentionDays == 0 {
+		return
+	}
+	oldest := now.AddDate(0, 0, -w.retentionDays).Format(time.DateOnly)
+	paths, err := filepath.Glob(filepath.Join(w.dir, "tagbot-*.log"))
+	if err != nil {
+		return
+	}
+	for _, path := range paths {
+		day := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "tagbot-"), ".log")
+		if _, err := time.Parse(time.DateOnly, day); err != nil || day >= oldest {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to remove old log file %s: %v\n", path, err)
+		}
+	}
+}
+
+// Close syncs and closes the current file. Later writes fail.
+func (w *rotatingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	w.file.Sync()
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// logf formats and logs a message, attributing it to the caller of the Log function
+func logf(l *slog.Logger, level slog.Level, format string, v []interface{}) {
+	ctx := context.Background()
+	if !l.Enabled(ctx, level) {
+		return
+	}
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:]) // skip Callers, logf and the Log function
+	record := slog.NewRecord(time.Now(), level, fmt.Sprintf(format, v...), pcs[0])
+	_ = l.Handler().Handle(ctx, record)
+}
+
+// LogDebug logs messages only useful when troubleshooting
+func LogDebug(format string, v ...interface{}) {
+	logf(logger, slog.LevelDebug, format, v)
 }
 
 // LogInfo logs informational messages
 func LogInfo(format string, v ...interface{}) {
-	msg := fmt.Sprintf(format, v...)
-	infoLogger.Output(2, msg)
-	log.Printf("INFO: %s", msg)
+	logf(logger, slog.LevelInfo, format, v)
+}
+
+// LogWarn logs problems the bot recovers from
+func LogWarn(format string, v ...interface{}) {
+	logf(logger, slog.LevelWarn, format, v)
 }
 
 // LogError logs error messages
 func LogError(format string, v ...interface{}) {
-	msg := fmt.Sprintf(format, v...)
-	errorLogger.Output(2, msg)
-	log.Printf("ERROR: %s", msg)
+	logf(logger, slog.LevelError, format, v)
 }
 
 // LogFatal logs fatal errors and exits the program
 func LogFatal(format string, v ...interface{}) {
-	msg := fmt.Sprintf(format, v...)
-	errorLogger.Output(2, msg)
-	log.Fatalf("FATAL: %s", msg)
+	logf(logger, levelFatal, format, v)
+	closeLogger()
+	os.Exit(1)
+}
+
+// updateLog logs with fields identifying the update being processed
+type updateLog struct {
+	logger *slog.Logger
+}
+
+// newUpdateLog returns a logger with the update_id, chat_id, user_id and command of an
+// update, leaving out the fields it doesn't have
+func newUpdateLog(update tgbotapi.Update) updateLog {
+	attrs := []any{slog.Int("update_id", update.UpdateID)}
+	var chat *tgbotapi.Chat
+	var user *tgbotapi.User
+	switch {
+	case update.Message != nil:
+		chat, user = update.Message.Chat, update.Message.From
+		if cmd := update.Message.Command(); cmd != "" {
+			attrs = append(attrs, slog.String("command", cmd))
+		}
+	case update.EditedMessage != nil:
+		chat, user = update.EditedMessage.Chat, update.EditedMessage.From
+	case update.ChatMember != nil:
+		chat, user = &update.ChatMember.Chat, &update.ChatMember.From
+	case update.MyChatMember != nil:
+		chat, user = &update.MyChatMember.Chat, &update.MyChatMember.From
+	case update.CallbackQuery != nil:
+		user = update.CallbackQuery.From
+		if update.CallbackQuery.Message != nil {
+			chat = update.CallbackQuery.Message.Chat
+		}
+	case update.PollAnswer != nil:
+		user = &update.PollAnswer.User
+	case update.InlineQuery != nil:
+		user = update.InlineQuery.From
+	}
+	if chat != nil {
+		attrs = append(attrs, slog.Int64("chat_id", chat.ID))
+	}
+	if user != nil {
+		attrs = append(attrs, slog.Int64("user_id", user.ID))
+	}
+	return updateLog{logger: logger.With(attrs...)}
+}
+
+func (u updateLog) Debug(format string, v ...interface{}) {
+	logf(u.logger, slog.LevelDebug, format, v)
+}
+
+func (u updateLog) Info(format string, v ...interface{}) {
+	logf(u.logger, slog.LevelInfo, format, v)
+}
+
+func (u updateLog) Warn(format string, v ...interface{}) {
+	logf(u.logger, slog.LevelWarn, format, v)
+}
+
+func (u updateLog) Error(format string, v ...interface{}) {
+	logf(u.logger, slog.LevelError, format, v)
 }
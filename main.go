@@ -1,8 +1,11 @@
 package main
 
 import (
-	"log"
+	"context"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
@@ -10,6 +13,12 @@ import (
 func main() {
 	// Initialize logger
 	initLogger()
+
+	// `tagbot migrate ...` manages the schema without starting the bot
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrateCommand(os.Args[2:])
+	}
+
 	LogInfo("Starting TagBot...")
 
 	botToken := os.Getenv("TELEGRAM_BOT_TOKEN")
@@ -18,7 +27,8 @@ func main() {
 	}
 
 	var err error
-	bot, err = tgbotapi.NewBotAPI(botToken)
+	// The client counts failed API calls for /metrics
+	bot, err = tgbotapi.NewBotAPIWithClient(botToken, tgbotapi.APIEndpoint, &telegramClient{next: &http.Client{Timeout: telegramRequestTimeout}})
 	if err != nil {
 		LogFatal("Failed to create bot: %v", err)
 	}
@@ -26,45 +36,63 @@ func main() {
 	bot.Debug = false
 	LogInfo("Authorized on account %s", bot.Self.UserName)
 
+	// Cancelled on SIGINT or SIGTERM, e.g. from docker stop
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	initDB()
 	initSpecialChatIDs()
+	initRelayOperatorIDs()
+	initMentionBatchSize()
+	startPollScheduler(ctx)
+	startChatPurger(ctx)
+	startWorkerPool()
 
-	// Register commands with Telegram client
-	commands := []tgbotapi.BotCommand{
-		{Command: "start", Description: "Show welcome message"},
-		{Command: "help", Description: "Show help message"},
-		{Command: "all", Description: "Mention all members"},
-	}
-	_, err = bot.Request(tgbotapi.NewSetMyCommands(commands...))
-	if err != nil {
-		LogError("Failed to set bot commands: %v", err)
+	// Register commands with Telegram client, translated for each supported language
+	for _, lang := range supportedLanguages {
+		config := tgbotapi.NewSetMyCommands(botCommands(lang)...)
+		if lang != defaultLanguage {
+			config.LanguageCode = lang
+		}
+		if _, err := bot.Request(config); err != nil {
+			LogError("Failed to set bot commands for language %s: %v", lang, err)
+		}
 	}
 
 	// Check if webhook mode is enabled
+	var server *http.Server
 	useWebhook := os.Getenv("USE_WEBHOOK")
 	if useWebhook == "true" || useWebhook == "1" {
 		LogInfo("Starting in webhook mode...")
-		startWebhookServer()
+		server = startWebhookServer()
+		<-ctx.Done()
 	} else {
 		LogInfo("Starting in polling mode...")
-		startPolling()
+		server = startMetricsServer()
+		startPolling(ctx)
 	}
+
+	timeout := shutdownTimeout()
+	LogInfo("Shutting down, waiting up to %s for in-flight work...", timeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	gracefulShutdown(shutdownCtx, server)
 }
 
-// startPolling starts the bot in polling mode (original behavior)
-func startPolling() {
+// startPolling starts the bot in polling mode (original behavior) and returns once ctx
+// is cancelled
+func startPolling(ctx context.Context) {
 	// Remove any existing webhook first
 	if err := removeWebhook(); err != nil {
-		log.Printf("Warning: Failed to remove existing webhook: %v", err)
+		LogWarn("Failed to remove existing webhook: %v", err)
 	}
 
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
+	// Resume after the last update processed before a restart. getUpdates doesn't
+	// watch ctx, so polling runs on its own and a long poll in progress is abandoned.
+	pollingOffsets = newOffsetTracker(loadPollingOffset())
+	startPollingOffsetFlusher(ctx)
+	go newUpdatePoller().Run(ctx)
 
-	updates := bot.GetUpdatesChan(u)
-
-	log.Println("Bot started in polling mode. Waiting for updates...")
-	for update := range updates {
-		processUpdate(update)
-	}
+	LogInfo("Bot started in polling mode. Waiting for updates...")
+	<-ctx.Done()
 }
@@ -0,0 +1,115 @@
+package main
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// seedMembersFromAdmins saves the chat's administrators as members and returns how many were saved
+func seedMembersFromAdmins(chatID int64) (int, error) {
+	admins, err := bot.GetChatAdministrators(tgbotapi.ChatAdministratorsConfig{
+		ChatConfig: tgbotapi.ChatConfig{ChatID: chatID},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	saved := 0
+	for _, admin := range admins {
+		if admin.User == nil || admin.User.IsBot {
+			continue
+		}
+		saveUser(chatID, admin.User)
+		saved++
+	}
+	return saved, nil
+}
+
+// handleNewChatMembers saves users added to the chat, as announced by the service message
+func handleNewChatMembers(message *tgbotapi.Message) {
+	for i := range message.NewChatMembers {
+		user := &message.NewChatMembers[i]
+		if user.IsBot {
+			continue
+		}
+		saveUser(message.Chat.ID, user)
+	}
+}
+
+// handleMyChatMemberUpdate tracks the bot joining and leaving chats. Joining seeds the
+// member list; leaving or being kicked marks the chat inactive until its data is purged.
+func handleMyChatMemberUpdate(update *tgbotapi.ChatMemberUpdated, ulog updateLog) {
+	chatID := update.Chat.ID
+	newStatus := update.NewChatMember.Status
+	ulog.Info("Bot status changed from %s to %s in chat %d", update.OldChatMember.Status, newStatus, chatID)
+
+	if update.Chat.IsPrivate() {
+		return
+	}
+
+	wasIn := isPresentStatus(update.OldChatMember)
+	isIn := isPresentStatus(update.NewChatMember)
+	if wasIn != isIn {
+		if err := setChatActive(chatID, update.Chat.Title, isIn); err != nil {
+			ulog.Error("Failed to update active state of chat %d: %v", chatID, err)
+		}
+	}
+
+	if isIn && !wasIn {
+		saved, err := seedMembersFromAdmins(chatID)
+		if err != nil {
+			ulog.Error("Failed to seed members of chat %d from administrators: %v", chatID, err)
+			return
+		}
+		ulog.Info("Seeded %d members of chat %d from administrators", saved, chatID)
+	}
+}
+
+// isPresentStatus reports whether a chat member status means the user is in the chat
+func isPresentStatus(member tgbotapi.ChatMember) bool {
+	switch member.Status {
+	case "creator", "administrator", "member":
+		return true
+	case "restricted":
+		return member.IsMember
+	default:
+		return false
+	}
+}
+
+// handleSyncCommand seeds members from the administrators and reports how many members
+// of the chat the bot knows about
+func handleSyncCommand(update tgbotapi.Update, ulog updateLog) {
+	chatID := update.Message.Chat.ID
+	lang := messageLanguage(update.Message)
+	if !isChatAdmin(chatID, update.Message.From.ID, ulog) {
+		sendText(chatID, tr(lang, "sync.admins_only"))
+		return
+	}
+
+	if _, err := seedMembersFromAdmins(chatID); err != nil {
+		ulog.Error("Failed to seed members of chat %d from administrators: %v", chatID, err)
+	}
+
+	known, err := countMembers(chatID)
+	if err != nil {
+		ulog.Error("Failed to count members of chat %d: %v", chatID, err)
+		sendText(chatID, tr(lang, "sync.count_failed"))
+		return
+	}
+
+	total, err := bot.GetChatMembersCount(tgbotapi.ChatMemberCountConfig{
+		ChatConfig: tgbotapi.ChatConfig{ChatID: chatID},
+	})
+	if err != nil {
+		ulog.Error("Failed to get member count of chat %d: %v", chatID, err)
+		sendText(chatID, tr(lang, "sync.known", known))
+		return
+	}
+
+	// The total includes the bot itself
+	reply := tr(lang, "sync.known_of", known, total-1)
+	if known < total-1 {
+		reply += "\n" + tr(lang, "sync.hint")
+	}
+	sendText(chatID, reply)
+}
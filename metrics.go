@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// The metrics are written in the Prometheus text exposition format by hand, which is
+// all /metrics needs and saves a dependency.
+
+// metric is anything that can write itself in the exposition format
+type metric interface {
+	write(w io.Writer)
+}
+
+var metricsRegistry []metric
+
+func register[M metric](m M) M {
+	metricsRegistry = append(metricsRegistry, m)
+	return m
+}
+
+// counterVec is a counter with labels
+type counterVec struct {
+	name, help string
+	labels     []string
+	mu         sync.Mutex
+	values     map[string]float64
+}
+
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	c := &counterVec{name: name, help: help, labels: labels, values: make(map[string]float64)}
+	if len(labels) == 0 {
+		c.values[""] = 0 // expose unlabelled counters before their first increment
+	}
+	return register(c)
+}
+
+// Add increases the counter for the label values, given in the order of the label names
+func (c *counterVec) Add(delta float64, labelValues ...string) {
+	key := formatLabels(c.labels, labelValues)
+	c.mu.Lock()
+	c.values[key] += delta
+	c.mu.Unlock()
+}
+
+func (c *counterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *counterVec) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, key, formatValue(c.values[key]))
+	}
+}
+
+// funcMetric is a gauge or counter whose value is read when the metrics are scraped
+type funcMetric struct {
+	name, help, kind string
+	value            func() float64
+}
+
+func newGaugeFunc(name, help string, value func() float64) *funcMetric {
+	return register(&funcMetric{name: name, help: help, kind: "gauge", value: value})
+}
+
+func newCounterFunc(name, help string, value func() float64) *funcMetric {
+	return register(&funcMetric{name: name, help: help, kind: "counter", value: value})
+}
+
+func (m *funcMetric) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", m.name, m.help, m.name, m.kind, m.name, formatValue(m.value()))
+}
+
+// histogramVec is a histogram with labels
+type histogramVec struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+	mu         sync.Mutex
+	series     map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *histogramVec {
+	return register(&histogramVec{name: name, help: help, labels: labels, buckets: buckets, series: make(map[string]*histogramSeries)})
+}
+
+func (h *histogramVec) Observe(value float64, labelValues ...string) {
+	key := formatLabels(h.labels, labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	series, ok := h.series[key]
+	if !ok {
+		series = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = series
+	}
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		series.counts[i]++
+	}
+	series.count++
+	series.sum += value
+}
+
+func (h *histogramVec) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range sortedKeys(h.series) {
+		series := h.series[key]
+		// The le label goes after the others, inside the same braces
+		prefix := "{"
+		if key != "" {
+			prefix = strings.TrimSuffix(key, "}") + ","
+		}
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += series.counts[i]
+			fmt.Fprintf(w, "%s_bucket%sle=\"%s\"} %d\n", h.name, prefix, formatValue(bound), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%sle=\"+Inf\"} %d\n", h.name, prefix, series.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, key, formatValue(series.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, key, series.count)
+	}
+}
+
+// formatLabels renders label pairs as {a="x",b="y"}, or "" without labels
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		pairs[i] = name + "=" + strconv.Quote(value)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Bot metrics
+var (
+	updatesTotal = newCounterVec("tagbot_updates_total",
+		"Updates received, by update type.", "type")
+	commandsTotal = newCounterVec("tagbot_commands_total",
+		"Bot commands handled, by command.", "command")
+	mentionMessagesTotal = newCounterVec("tagbot_mention_messages_total",
+		"Mention messages sent, by result.", "result")
+	membersMentionedTotal = newCounterVec("tagbot_members_mentioned_total",
+		"Members included in mass mentions.")
+	relayForwardsTotal = newCounterVec("tagbot_relay_forwards_total",
+		"Messages relayed to special chats, with @sendto or as special chat replies, by route and result.", "route", "result")
+	telegramAPIErrorsTotal = newCounterVec("tagbot_telegram_api_errors_total",
+		"Failed Telegram Bot API calls, by method and error code.", "method", "code")
+	webhookRejectedTotal = newCounterFunc("tagbot_webhook_rejected_total",
+		"Webhook requests rejected for a missing or invalid secret token.",
+		func() float64 { return float64(rejectedWebhookRequests.Load()) })
+	dbQueryDuration = newHistogramVec("tagbot_db_query_duration_seconds",
+		"Database query latency, by operation.",
+		[]float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5}, "operation")
+	workerQueueDepth = newGaugeFunc("tagbot_worker_queue_depth",
+		"Updates waiting for a worker.",
+		func() float64 {
+			if workers == nil {
+				return 0
+			}
+			return float64(workers.Depth())
+		})
+)
+
+// metricsHandler serves all registered metrics
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	for _, m := range metricsRegistry {
+		m.write(w)
+	}
+}
+
+// resultLabel turns an error into the result label of a metric
+func resultLabel(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+// updateType names the kind of an update for metrics
+func updateType(update tgbotapi.Update) string {
+	switch {
+	case update.Message != nil:
+		return "message"
+	case update.EditedMessage != nil:
+		return "edited_message"
+	case update.ChatMember != nil:
+		return "chat_member"
+	case update.MyChatMember != nil:
+		return "my_chat_member"
+	case update.PollAnswer != nil:
+		return "poll_answer"
+	case update.CallbackQuery != nil:
+		return "callback_query"
+	case update.InlineQuery != nil:
+		return "inline_query"
+	default:
+		return "other"
+	}
+}
+
+// commandLabel keeps the command label bounded to the commands the bot knows
+func commandLabel(cmd string) string {
+	if slices.Contains(commandNames, cmd) || cmd == "allow" || cmd == "disallow" {
+		return cmd
+	}
+	return "unknown"
+}
+
+// instrumentedDB times the queries run outside of transactions
+type instrumentedDB struct {
+	*sql.DB
+}
+
+func (d *instrumentedDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	defer observeQuery("exec", time.Now())
+	return d.DB.Exec(query, args...)
+}
+
+func (d *instrumentedDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	defer observeQuery("query", time.Now())
+	return d.DB.Query(query, args...)
+}
+
+func (d *instrumentedDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	defer observeQuery("query_row", time.Now())
+	return d.DB.QueryRow(query, args...)
+}
+
+func observeQuery(operation string, start time.Time) {
+	dbQueryDuration.Observe(time.Since(start).Seconds(), operation)
+}
+
+// telegramRequestTimeout bounds every Bot API call. Long polls take up to 60 seconds.
+const telegramRequestTimeout = 90 * time.Second
+
+// lastTelegramSuccess is the Unix time of the last successful Telegram API call
+var lastTelegramSuccess atomic.Int64
+
+// telegramClient wraps the bot's HTTP client to count failed API calls by method and
+// error code, and to remember when a call last succeeded
+type telegramClient struct {
+	next tgbotapi.HTTPClient
+}
+
+func (c *telegramClient) Do(req *http.Request) (*http.Response, error) {
+	method := path.Base(req.URL.Path)
+	resp, err := c.next.Do(req)
+	if err != nil {
+		telegramAPIErrorsTotal.Inc(method, "network")
+		return resp, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		telegramAPIErrorsTotal.Inc(method, "network")
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	var result struct {
+		OK        bool `json:"ok"`
+		ErrorCode int  `json:"error_code"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil || !result.OK {
+		code := strconv.Itoa(result.ErrorCode)
+		if result.ErrorCode == 0 {
+			code = strconv.Itoa(resp.StatusCode)
+		}
+		telegramAPIErrorsTotal.Inc(method, code)
+	} else {
+		lastTelegramSuccess.Store(time.Now().Unix())
+	}
+	return resp, nil
+}
+
+// startMetricsServer serves /metrics, /healthz and /readyz on METRICS_PORT in polling mode.
+// In webhook mode they are served by the webhook server instead. It returns nil if not enabled.
+func startMetricsServer() *http.Server {
+	port := os.Getenv("METRICS_PORT")
+	if port == "" {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	registerStatusHandlers(mux, false)
+	server := &http.Server{Addr: ":" + port, Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			LogError("Metrics server failed: %v", err)
+		}
+	}()
+	LogInfo("Serving metrics and health checks on port %s", port)
+	return server
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The schema is built by the numbered migrations in migrations/, named
+// <version>_<name>.up.sql and <version>_<name>.down.sql. Applied versions are recorded in
+// schema_migrations. Migrations never change once released; schema changes get a new one.
+//
+// The up migrations use IF NOT EXISTS, so a database created before schema_migrations
+// existed is adopted by running them all.
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID is the Postgres advisory lock held while migrating, so replicas starting
+// at the same time apply each migration once
+const migrationLockID = 7_412_355_110
+
+// migration is one schema version with the SQL to apply and revert it
+type migration struct {
+	version  int
+	name     string
+	up, down string
+}
+
+// appliedMigration is a row of schema_migrations
+type appliedMigration struct {
+	version   int
+	name      string
+	appliedAt time.Time
+}
+
+// loadMigrations reads the embedded migrations, sorted by version
+func loadMigrations() ([]migration, error) {
+	return parseMigrations(migrationFiles, "migrations")
+}
+
+// parseMigrations reads the migrations in dir, checking that every version has one name and
+// both an up and a down file
+func parseMigrations(fsys fs.FS, dir string) ([]migration, error) {
+	files, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*migration)
+	for _, file := range files {
+		base, direction, ok := strings.Cut(strings.TrimSuffix(file.Name(), ".sql"), ".")
+		versionStr, name, found := strings.Cut(base, "_")
+		version, err := strconv.Atoi(versionStr)
+		if !ok || !found || err != nil || version <= 0 || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("invalid migration file name %s", file.Name())
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &migration{version: version, name: name}
+			byVersion[version] = m
+		} else if m.name != name {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, m.name, name)
+		}
+		if direction == "up" {
+			m.up = string(content)
+		} else {
+			m.down = string(content)
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", m.version, m.name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	return migrations, nil
+}
+
+// withMigrationLock runs fn on a connection holding the migration advisory lock. The lock
+// belongs to the session, so everything must run on that one connection.
+func withMigrationLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("acquire migration lock failed: %w", err)
+	}
+	defer func() {
+		// Closing the connection would release the lock as well, but it goes back to the pool
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID); err != nil {
+			LogError("Failed to release migration lock: %v", err)
+		}
+	}()
+
+	query := `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	`
+	if _, err := conn.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("create schema_migrations failed: %w", err)
+	}
+	return fn(conn)
+}
+
+// querier is a *sql.DB or the *sql.Conn holding the migration lock
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// appliedMigrations returns the applied migrations by version
+func appliedMigrations(ctx context.Context, q querier) (map[int]appliedMigration, error) {
+	rows, err := q.QueryContext(ctx, "SELECT version, name, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("load applied migrations failed: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var m appliedMigration
+		if err := rows.Scan(&m.version, &m.name, &m.appliedAt); err != nil {
+			return nil, err
+		}
+		applied[m.version] = m
+	}
+	return applied, rows.Err()
+}
+
+// runMigration runs a migration's SQL and records it in schema_migrations in one
+// transaction, so a failing migration leaves nothing behind
+func runMigration(ctx context.Context, conn *sql.Conn, m migration, up bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statement, record := m.down, "DELETE FROM schema_migrations WHERE version = $1"
+	if up {
+		statement, record = m.up, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)"
+	}
+	if _, err := tx.ExecContext(ctx, statement); err != nil {
+		return err
+	}
+	args := []interface{}{m.version}
+	if up {
+		args = append(args, m.name)
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// migrateUp applies all pending migrations in order and returns how many were applied
+func migrateUp(ctx context.Context) (int, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err = withMigrationLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			if _, ok := applied[m.version]; ok {
+				continue
+			}
+			if err := runMigration(ctx, conn, m, true); err != nil {
+				return fmt.Errorf("migration %04d_%s failed: %w", m.version, m.name, err)
+			}
+			LogInfo("Applied migration %04d_%s", m.version, m.name)
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// readAppliedMigrations returns the applied migrations without taking the migration lock
+// or creating schema_migrations, for checks that must not change the database
+func readAppliedMigrations(ctx context.Context) (map[int]appliedMigration, error) {
+	var exists bool
+	if err := db.QueryRowContext(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists); err != nil {
+		return nil, fmt.Errorf("check schema_migrations failed: %w", err)
+	}
+	if !exists {
+		return map[int]appliedMigration{}, nil
+	}
+	return appliedMigrations(ctx, db)
+}
+
+// pendingMigrations returns the number of migrations not applied yet
+func pendingMigrations(ctx context.Context) (int, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return 0, err
+	}
+	applied, err := readAppliedMigrations(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	pending := 0
+	for _, m := range migrations {
+		if _, ok := applied[m.version]; !ok {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// migrateDown reverts the latest steps applied migrations
+func migrateDown(ctx context.Context, steps int) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	return withMigrationLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+			m := migrations[i]
+			if _, ok := applied[m.version]; !ok {
+				continue
+			}
+			if err := runMigration(ctx, conn, m, false); err != nil {
+				return fmt.Errorf("reverting migration %04d_%s failed: %w", m.version, m.name, err)
+			}
+			LogInfo("Reverted migration %04d_%s", m.version, m.name)
+			steps--
+		}
+		return nil
+	})
+}
+
+// printMigrationStatus lists every migration and when it was applied
+func printMigrationStatus(ctx context.Context) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	applied, err := readAppliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		status := "pending"
+		if a, ok := applied[m.version]; ok {
+			status = "applied " + a.appliedAt.Format(time.RFC3339)
+		}
+		fmt.Printf("%04d_%-30s %s\n", m.version, m.name, status)
+	}
+	// Versions missing from this build, e.g. after rolling back to an older release
+	known := make(map[int]bool, len(migrations))
+	for _, m := range migrations {
+		known[m.version] = true
+	}
+	for version, a := range applied {
+		if !known[version] {
+			fmt.Printf("%04d_%-30s applied %s, unknown to this build\n", version, a.name, a.appliedAt.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// runMigrateCommand handles `tagbot migrate up|down [steps]|status` and exits
+func runMigrateCommand(args []string) {
+	usage := "Usage: tagbot migrate up | down [steps] | status"
+	if len(args) == 0 {
+		LogFatal("%s", usage)
+	}
+
+	openDB()
+	ctx := context.Background()
+
+	var err error
+	switch args[0] {
+	case "up":
+		var count int
+		count, err = migrateUp(ctx)
+		if err == nil {
+			LogInfo("Applied %d migrations", count)
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				LogFatal("Invalid number of steps: %s", args[1])
+			}
+		}
+		err = migrateDown(ctx, steps)
+	case "status":
+		err = printMigrationStatus(ctx)
+	default:
+		LogFatal("%s", usage)
+	}
+	if err != nil {
+		LogFatal("Migration failed: %v", err)
+	}
+
+	db.Close()
+	closeLogger()
+	os.Exit(0)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("loadMigrations() = %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no embedded migrations")
+	}
+	// Versions are numbered without gaps, so a skipped or renumbered file stands out
+	for i, m := range migrations {
+		if m.version != i+1 {
+			t.Errorf("migration %d is %04d_%s, want version %d", i, m.version, m.name, i+1)
+		}
+	}
+}
+
+func TestParseMigrations(t *testing.T) {
+	file := func(content string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(content)} }
+	tests := []struct {
+		name    string
+		files   fstest.MapFS
+		wantErr string
+	}{
+		{
+			name: "paired files sorted by version",
+			files: fstest.MapFS{
+				"m/0002_b.up.sql":   file("up 2"),
+				"m/0002_b.down.sql": file("down 2"),
+				"m/0001_a.up.sql":   file("up 1"),
+				"m/0001_a.down.sql": file("down 1"),
+			},
+		},
+		{
+			name:    "missing down file",
+			files:   fstest.MapFS{"m/0001_a.up.sql": file("up")},
+			wantErr: "needs both an up and a down file",
+		},
+		{
+			name: "version with two names",
+			files: fstest.MapFS{
+				"m/0001_a.up.sql":   file("up"),
+				"m/0001_b.down.sql": file("down"),
+			},
+			wantErr: "is named both",
+		},
+		{
+			name:    "no version",
+			files:   fstest.MapFS{"m/create_a.up.sql": file("up")},
+			wantErr: "invalid migration file name",
+		},
+		{
+			name:    "unknown direction",
+			files:   fstest.MapFS{"m/0001_a.sideways.sql": file("up")},
+			wantErr: "invalid migration file name",
+		},
+		{
+			name:    "version zero",
+			files:   fstest.MapFS{"m/0000_a.up.sql": file("up"), "m/0000_a.down.sql": file("down")},
+			wantErr: "invalid migration file name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := parseMigrations(tt.files, "m")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseMigrations() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseMigrations() = %v", err)
+			}
+			if len(migrations) != 2 || migrations[0].version != 1 || migrations[1].version != 2 {
+				t.Fatalf("parseMigrations() = %+v, want versions 1 and 2", migrations)
+			}
+			if migrations[0].name != "a" || migrations[0].up != "up 1" || migrations[0].down != "down 1" {
+				t.Errorf("migration 1 = %+v", migrations[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS members (
+	chat_id BIGINT NOT NULL,
+	user_id BIGINT NOT NULL,
+	first_name TEXT,
+	last_name TEXT,
+	username TEXT,
+	PRIMARY KEY (chat_id, user_id)
+);
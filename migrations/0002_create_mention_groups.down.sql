@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS mention_group_members;
+DROP TABLE IF EXISTS mention_groups;
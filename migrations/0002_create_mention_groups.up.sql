@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS mention_groups (
+	chat_id BIGINT NOT NULL,
+	group_name TEXT NOT NULL,
+	created_by BIGINT,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (chat_id, group_name)
+);
+
+CREATE TABLE IF NOT EXISTS mention_group_members (
+	chat_id BIGINT NOT NULL,
+	group_name TEXT NOT NULL,
+	user_id BIGINT NOT NULL,
+	PRIMARY KEY (chat_id, group_name, user_id),
+	FOREIGN KEY (chat_id, group_name) REFERENCES mention_groups (chat_id, group_name) ON DELETE CASCADE
+);
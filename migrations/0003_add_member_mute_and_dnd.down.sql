@@ -0,0 +1,2 @@
+ALTER TABLE members DROP COLUMN IF EXISTS dnd_until;
+ALTER TABLE members DROP COLUMN IF EXISTS muted;
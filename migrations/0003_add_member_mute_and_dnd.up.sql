@@ -0,0 +1,2 @@
+ALTER TABLE members ADD COLUMN IF NOT EXISTS muted BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE members ADD COLUMN IF NOT EXISTS dnd_until TIMESTAMPTZ;
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS chat_settings (
+	chat_id BIGINT PRIMARY KEY,
+	chat_cooldown_seconds INTEGER,
+	user_cooldown_seconds INTEGER
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS mention_allowlist;
+ALTER TABLE chat_settings DROP COLUMN IF EXISTS mention_policy;
@@ -0,0 +1,8 @@
+ALTER TABLE chat_settings ADD COLUMN IF NOT EXISTS mention_policy TEXT;
+
+CREATE TABLE IF NOT EXISTS mention_allowlist (
+	chat_id BIGINT NOT NULL,
+	user_id BIGINT NOT NULL,
+	added_by BIGINT,
+	PRIMARY KEY (chat_id, user_id)
+);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS relay_audit (
+	id BIGSERIAL PRIMARY KEY,
+	operator_id BIGINT NOT NULL,
+	source_chat_id BIGINT NOT NULL,
+	target_chat_id BIGINT NOT NULL,
+	kind TEXT NOT NULL,
+	content TEXT,
+	error TEXT,
+	relayed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS relay_messages (
+	special_chat_id BIGINT NOT NULL,
+	special_message_id INTEGER NOT NULL,
+	origin_chat_id BIGINT NOT NULL,
+	origin_message_id INTEGER NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (special_chat_id, special_message_id)
+);
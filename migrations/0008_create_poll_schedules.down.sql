@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS poll_schedules;
+ALTER TABLE chat_settings DROP COLUMN IF EXISTS timezone;
@@ -0,0 +1,12 @@
+ALTER TABLE chat_settings ADD COLUMN IF NOT EXISTS timezone TEXT;
+
+CREATE TABLE IF NOT EXISTS poll_schedules (
+	id BIGSERIAL PRIMARY KEY,
+	chat_id BIGINT NOT NULL,
+	poll_time TEXT NOT NULL,
+	question TEXT NOT NULL,
+	options TEXT[] NOT NULL,
+	last_sent_date DATE,
+	created_by BIGINT,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
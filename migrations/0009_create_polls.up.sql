@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS polls (
+	poll_id TEXT PRIMARY KEY,
+	chat_id BIGINT NOT NULL,
+	message_id INTEGER NOT NULL,
+	poll_date DATE NOT NULL,
+	question TEXT NOT NULL,
+	options TEXT[] NOT NULL,
+	schedule_id BIGINT,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS polls_chat_date_idx ON polls (chat_id, poll_date);
+
+CREATE TABLE IF NOT EXISTS poll_votes (
+	poll_id TEXT NOT NULL REFERENCES polls (poll_id) ON DELETE CASCADE,
+	user_id BIGINT NOT NULL,
+	option_ids INTEGER[] NOT NULL,
+	voted_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (poll_id, user_id)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE poll_schedules DROP COLUMN IF EXISTS last_reminded_date;
+ALTER TABLE poll_schedules DROP COLUMN IF EXISTS remind_before_minutes;
+ALTER TABLE poll_schedules DROP COLUMN IF EXISTS game_time;
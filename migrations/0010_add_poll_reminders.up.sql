@@ -0,0 +1,3 @@
+ALTER TABLE poll_schedules ADD COLUMN IF NOT EXISTS game_time TEXT;
+ALTER TABLE poll_schedules ADD COLUMN IF NOT EXISTS remind_before_minutes INTEGER;
+ALTER TABLE poll_schedules ADD COLUMN IF NOT EXISTS last_reminded_date DATE;
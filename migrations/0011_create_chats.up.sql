@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS chats (
+	chat_id BIGINT PRIMARY KEY,
+	title TEXT,
+	active BOOLEAN NOT NULL DEFAULT TRUE,
+	left_at TIMESTAMPTZ
+);
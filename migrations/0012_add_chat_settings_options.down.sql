@@ -0,0 +1,3 @@
+ALTER TABLE chat_settings DROP COLUMN IF EXISTS polls_enabled;
+ALTER TABLE chat_settings DROP COLUMN IF EXISTS forward_to_special;
+ALTER TABLE chat_settings DROP COLUMN IF EXISTS language;
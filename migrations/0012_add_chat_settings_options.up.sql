@@ -0,0 +1,3 @@
+ALTER TABLE chat_settings ADD COLUMN IF NOT EXISTS language TEXT;
+ALTER TABLE chat_settings ADD COLUMN IF NOT EXISTS forward_to_special BOOLEAN;
+ALTER TABLE chat_settings ADD COLUMN IF NOT EXISTS polls_enabled BOOLEAN;
@@ -0,0 +1,3 @@
+ALTER TABLE chat_settings DROP COLUMN IF EXISTS help_template;
+ALTER TABLE chat_settings DROP COLUMN IF EXISTS start_template;
+ALTER TABLE chat_settings DROP COLUMN IF EXISTS persona;
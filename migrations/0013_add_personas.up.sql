@@ -0,0 +1,3 @@
+ALTER TABLE chat_settings ADD COLUMN IF NOT EXISTS persona TEXT;
+ALTER TABLE chat_settings ADD COLUMN IF NOT EXISTS start_template TEXT;
+ALTER TABLE chat_settings ADD COLUMN IF NOT EXISTS help_template TEXT;
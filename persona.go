@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Bundled personas for the /start and /help texts, described by "persona.<name>.*" messages
+const (
+	personaWerewolf = "werewolf"
+	personaNeutral  = "neutral"
+	personaOffice   = "office"
+)
+
+// defaultPersona is used for chats that haven't picked one
+const defaultPersona = personaWerewolf
+
+var personas = []string{personaWerewolf, personaNeutral, personaOffice}
+
+// maxTemplateLength keeps custom texts well below the message limit once variables are filled in
+const maxTemplateLength = 3000
+
+// sendPersonaText sends the chat's /start or /help text: the custom template if an admin
+// set one, otherwise the text of the chat's persona in the reply language
+func sendPersonaText(message *tgbotapi.Message, kind string, ulog updateLog) {
+	chatID := message.Chat.ID
+	lang := messageLanguage(message)
+
+	settings, err := getChatSettings(chatID)
+	if err != nil {
+		ulog.Error("Failed to load settings for chat %d: %v", chatID, err)
+		settings = defaultChatSettings()
+	}
+
+	template := settings.StartTemplate
+	if kind == "help" {
+		template = settings.HelpTemplate
+	}
+	if template == "" {
+		template = tr(lang, "persona."+personaOrDefault(settings.Persona)+"."+kind)
+	}
+
+	msg := tgbotapi.NewMessage(chatID, escapeMarkdownV2(renderTemplate(template, message, lang)))
+	msg.ParseMode = "MarkdownV2"
+	if _, err := bot.Send(msg); err != nil {
+		ulog.Error("Failed to send %s message to chat %d: %v", kind, chatID, err)
+	}
+}
+
+// renderTemplate fills in the template variables. Values are substituted as plain text;
+// the result is escaped as a whole, so neither the template nor the values can inject markup.
+func renderTemplate(template string, message *tgbotapi.Message, lang string) string {
+	chatTitle := message.Chat.Title
+	if chatTitle == "" {
+		chatTitle = displayName(message.From)
+	}
+
+	commands := make([]string, 0, len(commandNames))
+	for _, command := range botCommands(lang) {
+		commands = append(commands, fmt.Sprintf("• /%s - %s", command.Command, command.Description))
+	}
+
+	return strings.NewReplacer(
+		"{chat_title}", chatTitle,
+		"{bot_name}", bot.Self.UserName,
+		"{user_name}", displayName(message.From),
+		"{commands}", strings.Join(commands, "\n"),
+	).Replace(template)
+}
+
+// personaOrDefault guards against personas stored by a newer or older version
+func personaOrDefault(persona string) string {
+	if slices.Contains(personas, persona) {
+		return persona
+	}
+	return defaultPersona
+}
+
+// handlePersonaCommand shows or sets the chat persona, e.g. /persona office
+func handlePersonaCommand(update tgbotapi.Update, ulog updateLog) {
+	chatID := update.Message.Chat.ID
+	lang := messageLanguage(update.Message)
+	arg := strings.ToLower(strings.TrimSpace(update.Message.CommandArguments()))
+
+	if arg == "" {
+		settings, err := getChatSettings(chatID)
+		if err != nil {
+			ulog.Error("Failed to load settings for chat %d: %v", chatID, err)
+			sendText(chatID, tr(lang, "settings.load_failed"))
+			return
+		}
+		reply := tr(lang, "persona.show", tr(lang, "persona."+personaOrDefault(settings.Persona)+".name"))
+		if settings.StartTemplate != "" {
+			reply += "\n" + tr(lang, "template.custom", "start", "start")
+		}
+		if settings.HelpTemplate != "" {
+			reply += "\n" + tr(lang, "template.custom", "help", "help")
+		}
+		sendText(chatID, reply)
+		return
+	}
+
+	if !isChatAdmin(chatID, update.Message.From.ID, ulog) {
+		sendText(chatID, tr(lang, "persona.admins_only"))
+		return
+	}
+	if !slices.Contains(personas, arg) {
+		sendText(chatID, tr(lang, "persona.usage"))
+		return
+	}
+
+	if err := setChatSetting(chatID, "persona", arg); err != nil {
+		ulog.Error("Failed to save persona for chat %d: %v", chatID, err)
+		sendText(chatID, tr(lang, "settings.save_failed"))
+		return
+	}
+	sendText(chatID, tr(lang, "persona.updated", tr(lang, "persona."+arg+".name")))
+}
+
+// handleTemplateCommand sets or resets a custom /start or /help text. The text follows the
+// kind, e.g. /template start Welcome to {chat_title}!, or is taken from the replied-to message.
+func handleTemplateCommand(update tgbotapi.Update, ulog updateLog) {
+	chatID := update.Message.Chat.ID
+	lang := messageLanguage(update.Message)
+
+	if !isChatAdmin(chatID, update.Message.From.ID, ulog) {
+		sendText(chatID, tr(lang, "template.admins_only"))
+		return
+	}
+
+	args := strings.TrimSpace(update.Message.CommandArguments())
+	fields := strings.Fields(args)
+	if len(fields) == 0 || (fields[0] != "start" && fields[0] != "help") {
+		sendText(chatID, tr(lang, "template.usage"))
+		return
+	}
+	kind := fields[0]
+	text := strings.TrimSpace(strings.TrimPrefix(args, kind))
+	if text == "" && update.Message.ReplyToMessage != nil {
+		text = strings.TrimSpace(update.Message.ReplyToMessage.Text)
+	}
+	if text == "" {
+		sendText(chatID, tr(lang, "template.usage"))
+		return
+	}
+
+	column := kind + "_template"
+	if strings.EqualFold(text, "reset") {
+		if err := setChatSetting(chatID, column, nil); err != nil {
+			ulog.Error("Failed to reset %s for chat %d: %v", column, chatID, err)
+			sendText(chatID, tr(lang, "settings.save_failed"))
+			return
+		}
+		sendText(chatID, tr(lang, "template.reset", kind))
+		return
+	}
+
+	if utf8.RuneCountInString(text) > maxTemplateLength {
+		sendText(chatID, tr(lang, "template.too_long", maxTemplateLength))
+		return
+	}
+	if err := setChatSetting(chatID, column, text); err != nil {
+		ulog.Error("Failed to save %s for chat %d: %v", column, chatID, err)
+		sendText(chatID, tr(lang, "settings.save_failed"))
+		return
+	}
+	sendText(chatID, tr(lang, "template.updated", kind, kind))
+}
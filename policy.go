@@ -0,0 +1,204 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Mention policies control who may trigger @all, /all and group mentions in a chat
+const (
+	mentionPolicyEveryone  = "everyone"
+	mentionPolicyAdmins    = "admins"
+	mentionPolicyAllowlist = "allowlist"
+)
+
+// adminCacheTTL is how long the result of getChatAdministrators is reused
+const adminCacheTTL = 5 * time.Minute
+
+type cachedAdmins struct {
+	userIDs   map[int64]bool
+	fetchedAt time.Time
+}
+
+var (
+	adminCacheMu sync.Mutex
+	adminCache   = make(map[int64]cachedAdmins)
+)
+
+// getChatAdminIDs returns the administrators of a chat, cached for adminCacheTTL
+func getChatAdminIDs(chatID int64) (map[int64]bool, error) {
+	adminCacheMu.Lock()
+	cached, ok := adminCache[chatID]
+	adminCacheMu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < adminCacheTTL {
+		return cached.userIDs, nil
+	}
+
+	admins, err := bot.GetChatAdministrators(tgbotapi.ChatAdministratorsConfig{
+		ChatConfig: tgbotapi.ChatConfig{ChatID: chatID},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := make(map[int64]bool, len(admins))
+	for _, admin := range admins {
+		if admin.User != nil {
+			userIDs[admin.User.ID] = true
+		}
+	}
+
+	adminCacheMu.Lock()
+	adminCache[chatID] = cachedAdmins{userIDs: userIDs, fetchedAt: time.Now()}
+	adminCacheMu.Unlock()
+	return userIDs, nil
+}
+
+// isChatAdmin reports whether the user is an administrator or the creator of the chat.
+// In private chats the user is always treated as the admin.
+func isChatAdmin(chatID int64, userID int64, ulog updateLog) bool {
+	if chatID > 0 {
+		return true
+	}
+	admins, err := getChatAdminIDs(chatID)
+	if err != nil {
+		ulog.Error("Failed to get administrators of chat %d: %v", chatID, err)
+		return false
+	}
+	return admins[userID]
+}
+
+// canMentionAll reports whether the sender may trigger a mass mention under the chat's policy.
+// When denied it replies with a short explanation.
+func canMentionAll(message *tgbotapi.Message, ulog updateLog) bool {
+	chatID := message.Chat.ID
+	userID := message.From.ID
+
+	settings, err := getChatSettings(chatID)
+	if err != nil {
+		ulog.Error("Failed to load settings for chat %d: %v", chatID, err)
+		settings = defaultChatSettings()
+	}
+
+	switch settings.MentionPolicy {
+	case mentionPolicyAdmins:
+		if isChatAdmin(chatID, userID, ulog) {
+			return true
+		}
+		sendText(chatID, tr(messageLanguage(message), "mention.denied_admins"))
+
+	case mentionPolicyAllowlist:
+		if isChatAdmin(chatID, userID, ulog) {
+			return true
+		}
+		allowed, err := isUserAllowlisted(chatID, userID)
+		if err != nil {
+			ulog.Error("Failed to check allowlist for user %d in chat %d: %v", userID, chatID, err)
+		}
+		if allowed {
+			return true
+		}
+		sendText(chatID, tr(messageLanguage(message), "mention.denied_allowed"))
+
+	default:
+		return true
+	}
+
+	ulog.Info("Denied mass mention from user %d in chat %d by %s policy", userID, chatID, settings.MentionPolicy)
+	return false
+}
+
+// handleMentionPolicyCommand shows or changes who may use mass mentions, e.g. /mention_policy admins
+func handleMentionPolicyCommand(update tgbotapi.Update, ulog updateLog) {
+	chatID := update.Message.Chat.ID
+	lang := messageLanguage(update.Message)
+	arg := strings.ToLower(strings.TrimSpace(update.Message.CommandArguments()))
+
+	if arg == "" {
+		settings, err := getChatSettings(chatID)
+		if err != nil {
+			ulog.Error("Failed to load settings for chat %d: %v", chatID, err)
+			sendText(chatID, tr(lang, "settings.load_failed"))
+			return
+		}
+		sendText(chatID, tr(lang, "policy.show", settings.MentionPolicy))
+		return
+	}
+
+	if !isChatAdmin(chatID, update.Message.From.ID, ulog) {
+		sendText(chatID, tr(lang, "policy.admins_only"))
+		return
+	}
+
+	switch arg {
+	case mentionPolicyEveryone, mentionPolicyAdmins, mentionPolicyAllowlist:
+	default:
+		sendText(chatID, tr(lang, "policy.usage"))
+		return
+	}
+
+	if err := setMentionPolicy(chatID, arg); err != nil {
+		ulog.Error("Failed to save mention policy for chat %d: %v", chatID, err)
+		sendText(chatID, tr(lang, "settings.save_failed"))
+		return
+	}
+	sendText(chatID, tr(lang, "policy.updated", arg))
+}
+
+// handleAllowlistCommand manages the users allowed to mention everyone under the allowlist policy.
+// /allow and /disallow take @usernames or a reply; /allowlist shows the list.
+func handleAllowlistCommand(update tgbotapi.Update, ulog updateLog) {
+	chatID := update.Message.Chat.ID
+	cmd := update.Message.Command()
+	lang := messageLanguage(update.Message)
+
+	if cmd == "allowlist" {
+		names, err := listAllowlistNames(chatID)
+		if err != nil {
+			ulog.Error("Failed to list allowlist in chat %d: %v", chatID, err)
+			sendText(chatID, tr(lang, "allowlist.load_failed"))
+			return
+		}
+		if len(names) == 0 {
+			sendText(chatID, tr(lang, "allowlist.empty"))
+			return
+		}
+		sendText(chatID, tr(lang, "allowlist.list", strings.Join(names, "\n• ")))
+		return
+	}
+
+	if !isChatAdmin(chatID, update.Message.From.ID, ulog) {
+		sendText(chatID, tr(lang, "allowlist.admins_only"))
+		return
+	}
+
+	userIDs, unknown := resolveGroupTargets(update, strings.Fields(update.Message.CommandArguments()))
+	if len(userIDs) == 0 && len(unknown) == 0 {
+		sendText(chatID, tr(lang, "allowlist.usage", cmd))
+		return
+	}
+
+	for _, userID := range userIDs {
+		var err error
+		if cmd == "allow" {
+			err = addToAllowlist(chatID, userID, update.Message.From.ID)
+		} else {
+			err = removeFromAllowlist(chatID, userID)
+		}
+		if err != nil {
+			ulog.Error("Failed to %s user %d in chat %d: %v", cmd, userID, chatID, err)
+			sendText(chatID, tr(lang, "allowlist.failed"))
+			return
+		}
+	}
+	ulog.Info("Allow-list in chat %d: %s %d users", chatID, cmd, len(userIDs))
+
+	reply := tr(lang, "allowlist.updated", cmd, len(userIDs))
+	if len(unknown) > 0 {
+		reply += "\n" + tr(lang, "users.unknown", strings.Join(unknown, ", "))
+	}
+	sendText(chatID, reply)
+}
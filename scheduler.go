@@ -0,0 +1,403 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // the Docker image ships without a zoneinfo database
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// defaultTimezone is used for chats that have not set a timezone
+const defaultTimezone = "UTC"
+
+const (
+	// schedulerInterval is how often the scheduler checks for due polls
+	schedulerInterval = 30 * time.Second
+
+	// pollGracePeriod is how late a poll may still be posted, e.g. after a restart
+	pollGracePeriod = time.Hour
+)
+
+// defaultPollOptions are used when /schedule_poll is given only a question
+var defaultPollOptions = []string{"Yes", "No", "Maybe"}
+
+// startPollScheduler posts scheduled polls in the background
+func startPollScheduler(ctx context.Context) {
+	backgroundTasks.Add(1)
+	go func() {
+		defer backgroundTasks.Done()
+		ticker := time.NewTicker(schedulerInterval)
+		defer ticker.Stop()
+
+		runDuePolls(time.Now())
+		runDueReminders(time.Now())
+		for {
+			select {
+			case <-ctx.Done():
+				LogInfo("Poll scheduler stopped")
+				return
+			case now := <-ticker.C:
+				runDuePolls(now)
+				runDueReminders(now)
+			}
+		}
+	}()
+	LogInfo("Poll scheduler started")
+}
+
+// runDuePolls posts every scheduled poll whose local time has come today and that has
+// not been sent yet. Sent dates are persisted, so restarts neither skip nor repeat polls.
+func runDuePolls(now time.Time) {
+	schedules, err := listPollSchedules(0)
+	if err != nil {
+		LogError("Failed to load poll schedules: %v", err)
+		return
+	}
+
+	for _, schedule := range schedules {
+		due, ok := pollDueTime(schedule, now)
+		if !ok || now.Before(due) || now.Sub(due) > pollGracePeriod {
+			continue
+		}
+
+		claimed, err := claimPollSchedule(schedule.ID, due)
+		if err != nil {
+			LogError("Failed to claim poll schedule %d: %v", schedule.ID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		postScheduledPoll(schedule, due)
+	}
+}
+
+// pollDueTime returns today's occurrence of the schedule in its chat's timezone,
+// or false if the poll was already sent today
+func pollDueTime(schedule pollSchedule, now time.Time) (time.Time, bool) {
+	return dueTimeToday(schedule, schedule.PollTime, schedule.LastSentDate, now)
+}
+
+// dueTimeToday returns today's occurrence of the HH:MM time of day in the schedule's timezone,
+// or false if lastDate is already today. time.Date normalizes times that fall into a DST gap,
+// so they fire right after it.
+func dueTimeToday(schedule pollSchedule, timeOfDay string, lastDate sql.NullTime, now time.Time) (time.Time, bool) {
+	loc, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		LogError("Invalid timezone %s for poll schedule %d: %v", schedule.Timezone, schedule.ID, err)
+		return time.Time{}, false
+	}
+	hour, minute, err := parsePollTime(timeOfDay)
+	if err != nil {
+		LogError("Invalid time %s for poll schedule %d: %v", timeOfDay, schedule.ID, err)
+		return time.Time{}, false
+	}
+
+	local := now.In(loc)
+	due := time.Date(local.Year(), local.Month(), local.Day(), hour, minute, 0, 0, loc)
+	if lastDate.Valid && lastDate.Time.Format("2006-01-02") >= due.Format("2006-01-02") {
+		return time.Time{}, false
+	}
+	return due, true
+}
+
+// passedToday returns the date of now if hour:minute has already passed on it in now's
+// location, or nil if it is still to come
+func passedToday(hour, minute int, now time.Time) *time.Time {
+	due := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+	if now.Before(due) {
+		return nil
+	}
+	return &due
+}
+
+// runDueReminders mentions the members who haven't answered today's scheduled poll, a
+// configured number of minutes before game time. Reminders are limited to the same day.
+func runDueReminders(now time.Time) {
+	schedules, err := listPollSchedules(0)
+	if err != nil {
+		LogError("Failed to load poll schedules: %v", err)
+		return
+	}
+
+	for _, schedule := range schedules {
+		if !schedule.GameTime.Valid || !schedule.RemindBefore.Valid {
+			continue
+		}
+		game, ok := dueTimeToday(schedule, schedule.GameTime.String, schedule.LastRemindedDate, now)
+		if !ok {
+			continue
+		}
+		due := game.Add(-time.Duration(schedule.RemindBefore.Int64) * time.Minute)
+		if due.Format("2006-01-02") != game.Format("2006-01-02") {
+			due = time.Date(game.Year(), game.Month(), game.Day(), 0, 0, 0, 0, game.Location())
+		}
+		if now.Before(due) || now.After(game) {
+			continue
+		}
+
+		poll, err := findSchedulePoll(schedule.ID, game)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			LogError("Failed to find today's poll for schedule %d: %v", schedule.ID, err)
+			continue
+		}
+
+		claimed, err := claimPollReminder(schedule.ID, game)
+		if err != nil {
+			LogError("Failed to claim reminder for poll schedule %d: %v", schedule.ID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		LogInfo("Sending automatic reminder for poll schedule %d in chat %d", schedule.ID, schedule.ChatID)
+		if err := remindNonVoters(poll, chatLanguage(poll.ChatID)); err != nil {
+			LogError("Failed to send reminder for poll %s in chat %d: %v", poll.PollID, schedule.ChatID, err)
+		}
+	}
+}
+
+func postScheduledPoll(schedule pollSchedule, due time.Time) {
+	poll := tgbotapi.NewPoll(schedule.ChatID, schedule.Question, schedule.Options...)
+	poll.IsAnonymous = false
+	sent, err := bot.Send(poll)
+	if err != nil {
+		LogError("Failed to post scheduled poll %d to chat %d: %v", schedule.ID, schedule.ChatID, err)
+		return
+	}
+	LogInfo("Posted scheduled poll %d to chat %d", schedule.ID, schedule.ChatID)
+	recordPostedPoll(sent, due, schedule.ID)
+}
+
+// chatLocation returns the chat's configured timezone, or UTC if it can't be loaded
+func chatLocation(chatID int64) *time.Location {
+	settings, err := getChatSettings(chatID)
+	if err != nil {
+		LogError("Failed to load settings for chat %d: %v", chatID, err)
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(settings.Timezone)
+	if err != nil {
+		LogError("Invalid timezone %s for chat %d: %v", settings.Timezone, chatID, err)
+		return time.UTC
+	}
+	return loc
+}
+
+// parsePollTime parses a 24-hour HH:MM time of day
+func parsePollTime(s string) (int, int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, 0, err
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+// parsePollArgs splits `"Question?" | Option 1 | Option 2` into a question and options
+func parsePollArgs(args string) (string, []string) {
+	parts := strings.Split(args, "|")
+	question := strings.Trim(strings.TrimSpace(parts[0]), `"“”`)
+
+	var options []string
+	for _, part := range parts[1:] {
+		if option := strings.TrimSpace(part); option != "" {
+			options = append(options, option)
+		}
+	}
+	if len(options) == 0 {
+		options = defaultPollOptions
+	}
+	return question, options
+}
+
+// handleSchedulePollCommand schedules a daily poll, e.g. /schedule_poll 19:30 "Who's playing tonight?"
+func handleSchedulePollCommand(update tgbotapi.Update, ulog updateLog) {
+	chatID := update.Message.Chat.ID
+	lang := messageLanguage(update.Message)
+	if !isChatAdmin(chatID, update.Message.From.ID, ulog) {
+		sendText(chatID, tr(lang, "schedule.admins_only"))
+		return
+	}
+
+	usage := tr(lang, "schedule.usage")
+	timeStr, rest, _ := strings.Cut(strings.TrimSpace(update.Message.CommandArguments()), " ")
+	hour, minute, err := parsePollTime(timeStr)
+	if err != nil {
+		sendText(chatID, usage)
+		return
+	}
+	question, options := parsePollArgs(rest)
+	if question == "" || len(options) < 2 || len(options) > 10 {
+		sendText(chatID, usage+"\n"+tr(lang, "schedule.options"))
+		return
+	}
+
+	loc := chatLocation(chatID)
+
+	// Don't post today's poll immediately if its time has already passed
+	lastSentDate := passedToday(hour, minute, time.Now().In(loc))
+
+	pollTime := fmt.Sprintf("%02d:%02d", hour, minute)
+	id, err := createPollSchedule(chatID, pollTime, question, options, lastSentDate, update.Message.From.ID)
+	if err != nil {
+		ulog.Error("Failed to create poll schedule in chat %d: %v", chatID, err)
+		sendText(chatID, tr(lang, "schedule.failed"))
+		return
+	}
+	sendText(chatID, tr(lang, "schedule.created", id, pollTime, loc, question))
+}
+
+// handleUnschedulePollCommand removes a schedule by ID, e.g. /unschedule_poll 3
+func handleUnschedulePollCommand(update tgbotapi.Update, ulog updateLog) {
+	chatID := update.Message.Chat.ID
+	lang := messageLanguage(update.Message)
+	if !isChatAdmin(chatID, update.Message.From.ID, ulog) {
+		sendText(chatID, tr(lang, "unschedule.admins_only"))
+		return
+	}
+
+	id, err := strconv.ParseInt(strings.TrimPrefix(strings.TrimSpace(update.Message.CommandArguments()), "#"), 10, 64)
+	if err != nil {
+		sendText(chatID, tr(lang, "unschedule.usage"))
+		return
+	}
+
+	deleted, err := deletePollSchedule(chatID, id)
+	if err != nil {
+		ulog.Error("Failed to delete poll schedule %d in chat %d: %v", id, chatID, err)
+		sendText(chatID, tr(lang, "unschedule.failed"))
+		return
+	}
+	if !deleted {
+		sendText(chatID, tr(lang, "schedule.not_found", id))
+		return
+	}
+	sendText(chatID, tr(lang, "unschedule.done", id))
+}
+
+// handleSchedulesCommand lists the chat's scheduled polls
+func handleSchedulesCommand(update tgbotapi.Update, ulog updateLog) {
+	chatID := update.Message.Chat.ID
+	lang := messageLanguage(update.Message)
+	schedules, err := listPollSchedules(chatID)
+	if err != nil {
+		ulog.Error("Failed to list poll schedules in chat %d: %v", chatID, err)
+		sendText(chatID, tr(lang, "schedules.failed"))
+		return
+	}
+	if len(schedules) == 0 {
+		sendText(chatID, tr(lang, "schedules.none"))
+		return
+	}
+
+	lines := make([]string, 0, len(schedules))
+	for _, schedule := range schedules {
+		line := tr(lang, "schedules.item", schedule.ID, schedule.PollTime, schedule.Question, strings.Join(schedule.Options, " / "))
+		if schedule.GameTime.Valid && schedule.RemindBefore.Valid {
+			line += tr(lang, "schedules.reminder", schedule.RemindBefore.Int64, schedule.GameTime.String)
+		}
+		lines = append(lines, line)
+	}
+	sendText(chatID, tr(lang, "schedules.list", schedules[0].Timezone, strings.Join(lines, "\n")))
+}
+
+// handleTimezoneCommand shows or sets the chat's IANA timezone, e.g. /timezone Asia/Ho_Chi_Minh
+func handleTimezoneCommand(update tgbotapi.Update, ulog updateLog) {
+	chatID := update.Message.Chat.ID
+	lang := messageLanguage(update.Message)
+	arg := strings.TrimSpace(update.Message.CommandArguments())
+
+	if arg == "" {
+		settings, err := getChatSettings(chatID)
+		if err != nil {
+			ulog.Error("Failed to load settings for chat %d: %v", chatID, err)
+			sendText(chatID, tr(lang, "settings.load_failed"))
+			return
+		}
+		sendText(chatID, tr(lang, "timezone.show", settings.Timezone))
+		return
+	}
+
+	if !isChatAdmin(chatID, update.Message.From.ID, ulog) {
+		sendText(chatID, tr(lang, "timezone.admins_only"))
+		return
+	}
+
+	loc, err := time.LoadLocation(arg)
+	if err != nil || arg == "Local" {
+		sendText(chatID, tr(lang, "timezone.unknown", arg))
+		return
+	}
+
+	if err := setChatTimezone(chatID, loc.String()); err != nil {
+		ulog.Error("Failed to save timezone for chat %d: %v", chatID, err)
+		sendText(chatID, tr(lang, "settings.save_failed"))
+		return
+	}
+	sendText(chatID, tr(lang, "timezone.updated", loc))
+}
+
+// handleAutoRemindCommand configures the automatic reminder of a scheduled poll,
+// e.g. /auto_remind 3 21:00 30 reminds non-voters 30 minutes before a 21:00 game
+func handleAutoRemindCommand(update tgbotapi.Update, ulog updateLog) {
+	chatID := update.Message.Chat.ID
+	lang := messageLanguage(update.Message)
+	if !isChatAdmin(chatID, update.Message.From.ID, ulog) {
+		sendText(chatID, tr(lang, "remind.admins_only"))
+		return
+	}
+
+	usage := tr(lang, "remind.usage")
+	args := strings.Fields(update.Message.CommandArguments())
+	if len(args) < 2 {
+		sendText(chatID, usage)
+		return
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(args[0], "#"), 10, 64)
+	if err != nil {
+		sendText(chatID, usage)
+		return
+	}
+
+	var gameTime string
+	var minutes int
+	if !(len(args) == 2 && strings.EqualFold(args[1], "off")) {
+		hour, minute, err := parsePollTime(args[1])
+		if len(args) != 3 || err != nil {
+			sendText(chatID, usage)
+			return
+		}
+		minutes, err = strconv.Atoi(args[2])
+		if err != nil || minutes < 0 || minutes > 12*60 {
+			sendText(chatID, tr(lang, "remind.minutes"))
+			return
+		}
+		gameTime = fmt.Sprintf("%02d:%02d", hour, minute)
+	}
+
+	updated, err := setPollReminder(chatID, id, gameTime, minutes)
+	if err != nil {
+		ulog.Error("Failed to set reminder for poll schedule %d in chat %d: %v", id, chatID, err)
+		sendText(chatID, tr(lang, "remind.failed"))
+		return
+	}
+	if !updated {
+		sendText(chatID, tr(lang, "schedule.not_found", id))
+		return
+	}
+	if gameTime == "" {
+		sendText(chatID, tr(lang, "remind.off", id))
+		return
+	}
+	sendText(chatID, tr(lang, "remind.on", id, minutes, gameTime))
+}
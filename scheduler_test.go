@@ -0,0 +1,126 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+// sentOn is a last_sent_date as read back from Postgres, midnight UTC of the local date
+func sentOn(date string) sql.NullTime {
+	t, err := time.Parse(time.DateOnly, date)
+	if err != nil {
+		panic(err)
+	}
+	return sql.NullTime{Time: t, Valid: true}
+}
+
+func TestPollDueTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		timezone string
+		pollTime string
+		lastSent sql.NullTime
+		now      time.Time
+		wantDue  time.Time
+		wantOK   bool
+	}{
+		{
+			name:     "later today",
+			timezone: "UTC",
+			pollTime: "19:30",
+			now:      time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+			wantDue:  time.Date(2024, 5, 1, 19, 30, 0, 0, time.UTC),
+			wantOK:   true,
+		},
+		{
+			name:     "already sent today",
+			timezone: "UTC",
+			pollTime: "19:30",
+			lastSent: sentOn("2024-05-01"),
+			now:      time.Date(2024, 5, 1, 19, 31, 0, 0, time.UTC),
+		},
+		{
+			// 02:30 doesn't exist on the day clocks go from 02:00 to 03:00, so the poll
+			// fires right after the gap
+			name:     "spring-forward gap",
+			timezone: "Europe/Berlin",
+			pollTime: "02:30",
+			now:      time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC),
+			wantDue:  time.Date(2024, 3, 31, 1, 30, 0, 0, time.UTC),
+			wantOK:   true,
+		},
+		{
+			// 02:30 happens twice on the day clocks go from 03:00 back to 02:00; the poll
+			// fires at the second one
+			name:     "fall-back overlap",
+			timezone: "Europe/Berlin",
+			pollTime: "02:30",
+			now:      time.Date(2024, 10, 27, 0, 0, 0, 0, time.UTC),
+			wantDue:  time.Date(2024, 10, 27, 1, 30, 0, 0, time.UTC),
+			wantOK:   true,
+		},
+		{
+			name:     "fall-back overlap after the poll was sent",
+			timezone: "Europe/Berlin",
+			pollTime: "02:30",
+			lastSent: sentOn("2024-10-27"),
+			now:      time.Date(2024, 10, 27, 1, 45, 0, 0, time.UTC),
+		},
+		{
+			// It's already January 11 in Auckland, so yesterday's poll doesn't count
+			name:     "local date ahead of UTC",
+			timezone: "Pacific/Auckland",
+			pollTime: "08:00",
+			lastSent: sentOn("2024-01-10"),
+			now:      time.Date(2024, 1, 10, 20, 0, 0, 0, time.UTC),
+			wantDue:  time.Date(2024, 1, 10, 19, 0, 0, 0, time.UTC),
+			wantOK:   true,
+		},
+		{
+			// It's still January 10 in Los Angeles, where the poll was already sent
+			name:     "local date behind UTC",
+			timezone: "America/Los_Angeles",
+			pollTime: "18:00",
+			lastSent: sentOn("2024-01-10"),
+			now:      time.Date(2024, 1, 11, 3, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule := pollSchedule{ID: 1, PollTime: tt.pollTime, Timezone: tt.timezone, LastSentDate: tt.lastSent}
+			due, ok := pollDueTime(schedule, tt.now)
+			if ok != tt.wantOK || !due.Equal(tt.wantDue) {
+				t.Errorf("pollDueTime() = %v, %v, want %v, %v", due, ok, tt.wantDue, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestScheduleCreatedAfterPollTime(t *testing.T) {
+	auckland, err := time.LoadLocation("Pacific/Auckland")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 09:00 on January 11 in Auckland, still January 10 in UTC
+	created := time.Date(2024, 1, 10, 20, 0, 0, 0, time.UTC).In(auckland)
+
+	if got := passedToday(10, 0, created); got != nil {
+		t.Errorf("passedToday(10:00) = %v at 09:00, want nil", got)
+	}
+	passed := passedToday(8, 0, created)
+	if passed == nil || passed.Format(time.DateOnly) != "2024-01-11" {
+		t.Fatalf("passedToday(08:00) = %v at 09:00, want 2024-01-11", passed)
+	}
+
+	// The 08:00 poll isn't posted on the day the schedule was created, but is the next day
+	schedule := pollSchedule{ID: 1, PollTime: "08:00", Timezone: "Pacific/Auckland", LastSentDate: sentOn(passed.Format(time.DateOnly))}
+	if due, ok := pollDueTime(schedule, created); ok {
+		t.Errorf("pollDueTime() = %v on the day the schedule was created, want not due", due)
+	}
+	nextDay := created.Add(24 * time.Hour)
+	if due, ok := pollDueTime(schedule, nextDay); !ok || due.Format(time.DateOnly) != "2024-01-12" {
+		t.Errorf("pollDueTime() = %v, %v the next day, want 2024-01-12 08:00", due, ok)
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// settingsCallbackPrefix marks callback data of the /settings panel, e.g. "settings:policy:admins"
+const settingsCallbackPrefix = "settings:"
+
+// settingsOption is a button of the settings panel. Labels that are message keys are translated.
+type settingsOption struct {
+	label string
+	value string
+}
+
+// text returns the button label in lang
+func (o settingsOption) text(lang string) string {
+	if _, ok := messages[defaultLanguage][o.label]; ok {
+		return tr(lang, o.label)
+	}
+	return o.label
+}
+
+var (
+	settingsPolicies = []settingsOption{
+		{"settings.everyone", mentionPolicyEveryone},
+		{"settings.admins", mentionPolicyAdmins},
+		{"settings.allowlist", mentionPolicyAllowlist},
+	}
+	settingsChatCooldowns = []settingsOption{
+		{"settings.off", "0"}, {"1m", "60"}, {"5m", "300"}, {"15m", "900"},
+	}
+	settingsUserCooldowns = []settingsOption{
+		{"settings.off", "0"}, {"5m", "300"}, {"15m", "900"}, {"1h", "3600"},
+	}
+	settingsLanguages = []settingsOption{
+		{"settings.auto", ""}, {languageNames["en"], "en"}, {languageNames["vi"], "vi"},
+	}
+	settingsTimezones = []settingsOption{
+		{"UTC", "UTC"}, {"Ho Chi Minh", "Asia/Ho_Chi_Minh"}, {"Berlin", "Europe/Berlin"}, {"New York", "America/New_York"},
+	}
+	settingsPersonas = []settingsOption{
+		{"persona.werewolf.name", personaWerewolf}, {"persona.neutral.name", personaNeutral}, {"persona.office.name", personaOffice},
+	}
+	settingsToggles = []settingsOption{
+		{"settings.on", "on"}, {"settings.off", "off"},
+	}
+)
+
+// handleSettingsCommand opens the inline keyboard settings panel of the chat
+func handleSettingsCommand(update tgbotapi.Update, ulog updateLog) {
+	chatID := update.Message.Chat.ID
+	lang := messageLanguage(update.Message)
+	if !isChatAdmin(chatID, update.Message.From.ID, ulog) {
+		sendText(chatID, tr(lang, "settings.admins_only"))
+		return
+	}
+
+	settings, err := getChatSettings(chatID)
+	if err != nil {
+		ulog.Error("Failed to load settings for chat %d: %v", chatID, err)
+		sendText(chatID, tr(lang, "settings.load_failed"))
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, escapeMarkdownV2(settingsText(lang, settings)))
+	msg.ParseMode = "MarkdownV2"
+	msg.ReplyMarkup = settingsKeyboard(lang, settings)
+	if _, err := bot.Send(msg); err != nil {
+		ulog.Error("Failed to send settings panel to chat %d: %v", chatID, err)
+	}
+}
+
+// handleSettingsCallback applies a button press on the settings panel and redraws it
+func handleSettingsCallback(query *tgbotapi.CallbackQuery, ulog updateLog) {
+	if query.Message == nil {
+		answerCallback(query.ID, "", ulog)
+		return
+	}
+	chatID := query.Message.Chat.ID
+	messageID := query.Message.MessageID
+
+	if !isChatAdmin(chatID, query.From.ID, ulog) {
+		answerCallback(query.ID, tr(resolveLanguage(chatID, query.From), "settings.admins_only"), ulog)
+		return
+	}
+
+	key, value, _ := strings.Cut(strings.TrimPrefix(query.Data, settingsCallbackPrefix), ":")
+	if key == "close" {
+		if _, err := bot.Request(tgbotapi.NewDeleteMessage(chatID, messageID)); err != nil {
+			ulog.Error("Failed to close settings panel in chat %d: %v", chatID, err)
+		}
+		answerCallback(query.ID, "", ulog)
+		return
+	}
+
+	if err := applySetting(chatID, key, value); err != nil {
+		ulog.Error("Failed to apply setting %s=%s in chat %d: %v", key, value, chatID, err)
+		answerCallback(query.ID, tr(resolveLanguage(chatID, query.From), "settings.failed"), ulog)
+		return
+	}
+	ulog.Info("User %d set %s=%s in chat %d", query.From.ID, key, value, chatID)
+
+	// Resolved after saving so a language change redraws the panel in the new language
+	lang := resolveLanguage(chatID, query.From)
+	settings, err := getChatSettings(chatID)
+	if err != nil {
+		ulog.Error("Failed to load settings for chat %d: %v", chatID, err)
+		answerCallback(query.ID, tr(lang, "settings.saved"), ulog)
+		return
+	}
+
+	edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, escapeMarkdownV2(settingsText(lang, settings)), settingsKeyboard(lang, settings))
+	edit.ParseMode = "MarkdownV2"
+	if _, err := bot.Request(edit); err != nil {
+		ulog.Error("Failed to update settings panel in chat %d: %v", chatID, err)
+	}
+	answerCallback(query.ID, tr(lang, "settings.saved"), ulog)
+}
+
+// applySetting validates and stores a single value chosen on the settings panel
+func applySetting(chatID int64, key, value string) error {
+	switch key {
+	case "policy":
+		if !hasOption(settingsPolicies, value) {
+			return fmt.Errorf("invalid mention policy %q", value)
+		}
+		return setMentionPolicy(chatID, value)
+
+	case "chat_cd", "user_cd":
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 0 || seconds > int((24*time.Hour).Seconds()) {
+			return fmt.Errorf("invalid cooldown %q", value)
+		}
+		column := "chat_cooldown_seconds"
+		if key == "user_cd" {
+			column = "user_cooldown_seconds"
+		}
+		return setChatSetting(chatID, column, seconds)
+
+	case "lang":
+		if !hasOption(settingsLanguages, value) {
+			return fmt.Errorf("invalid language %q", value)
+		}
+		return setChatSetting(chatID, "language", value)
+
+	case "tz":
+		if _, err := time.LoadLocation(value); err != nil || !hasOption(settingsTimezones, value) {
+			return fmt.Errorf("invalid timezone %q", value)
+		}
+		return setChatTimezone(chatID, value)
+
+	case "persona":
+		if !hasOption(settingsPersonas, value) {
+			return fmt.Errorf("invalid persona %q", value)
+		}
+		return setChatSetting(chatID, "persona", value)
+
+	case "forward", "polls":
+		if !hasOption(settingsToggles, value) {
+			return fmt.Errorf("invalid toggle %q", value)
+		}
+		column := "forward_to_special"
+		if key == "polls" {
+			column = "polls_enabled"
+		}
+		return setChatSetting(chatID, column, value == "on")
+
+	default:
+		return fmt.Errorf("unknown setting %q", key)
+	}
+}
+
+func hasOption(options []settingsOption, value string) bool {
+	for _, option := range options {
+		if option.value == value {
+			return true
+		}
+	}
+	return false
+}
+
+func settingsText(lang string, settings chatSettings) string {
+	language := tr(lang, "settings.auto_language")
+	if settings.Language != "" {
+		language = languageNames[settings.Language]
+	}
+	return tr(lang, "settings.text",
+		settings.MentionPolicy,
+		formatDuration(settings.ChatCooldown),
+		formatDuration(settings.UserCooldown),
+		language,
+		tr(lang, "settings."+onOff(settings.ForwardToSpecial)),
+		tr(lang, "settings."+onOff(settings.PollsEnabled)),
+		settings.Timezone,
+		tr(lang, "persona."+personaOrDefault(settings.Persona)+".name"),
+	)
+}
+
+func onOff(enabled bool) string {
+	if enabled {
+		return "on"
+	}
+	return "off"
+}
+
+// settingsKeyboard builds one row of buttons per setting, marking the current values
+func settingsKeyboard(lang string, settings chatSettings) tgbotapi.InlineKeyboardMarkup {
+	seconds := func(d time.Duration) string { return strconv.Itoa(int(d.Seconds())) }
+	return tgbotapi.NewInlineKeyboardMarkup(
+		settingsRow(lang, "policy", settingsPolicies, settings.MentionPolicy),
+		settingsRow(lang, "chat_cd", settingsChatCooldowns, seconds(settings.ChatCooldown)),
+		settingsRow(lang, "user_cd", settingsUserCooldowns, seconds(settings.UserCooldown)),
+		settingsRow(lang, "lang", settingsLanguages, settings.Language),
+		settingsRow(lang, "forward", settingsToggles, onOff(settings.ForwardToSpecial)),
+		settingsRow(lang, "polls", settingsToggles, onOff(settings.PollsEnabled)),
+		settingsRow(lang, "tz", settingsTimezones, settings.Timezone),
+		settingsRow(lang, "persona", settingsPersonas, personaOrDefault(settings.Persona)),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(tr(lang, "settings.close"), settingsCallbackPrefix+"close")),
+	)
+}
+
+func settingsRow(lang, key string, options []settingsOption, current string) []tgbotapi.InlineKeyboardButton {
+	prefixes := map[string]string{
+		"policy":  "👥 ",
+		"chat_cd": "⏱ ",
+		"user_cd": "👤 ",
+		"lang":    "🌐 ",
+		"forward": "📨 ",
+		"polls":   "📊 ",
+		"tz":      "🕒 ",
+	}
+
+	buttons := make([]tgbotapi.InlineKeyboardButton, 0, len(options))
+	for i, option := range options {
+		label := option.text(lang)
+		if i == 0 {
+			label = prefixes[key] + label
+		}
+		if option.value == current {
+			label = "✅ " + label
+		}
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(label, settingsCallbackPrefix+key+":"+option.value))
+	}
+	return tgbotapi.NewInlineKeyboardRow(buttons...)
+}
+
+// answerCallback acknowledges a callback query, optionally showing a short notification
+func answerCallback(queryID, text string, ulog updateLog) {
+	if _, err := bot.Request(tgbotapi.NewCallback(queryID, text)); err != nil {
+		ulog.Error("Failed to answer callback query %s: %v", queryID, err)
+	}
+}
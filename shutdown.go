@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// defaultShutdownTimeout bounds the whole shutdown unless SHUTDOWN_TIMEOUT is set
+const defaultShutdownTimeout = 30 * time.Second
+
+// backgroundTasks tracks the scheduler and purger goroutines so shutdown can wait
+// for a run in progress before closing the database
+var backgroundTasks sync.WaitGroup
+
+// shutdownTimeout reads SHUTDOWN_TIMEOUT, e.g. 30s or 2m
+func shutdownTimeout() time.Duration {
+	timeoutStr := os.Getenv("SHUTDOWN_TIMEOUT")
+	if timeoutStr == "" {
+		return defaultShutdownTimeout
+	}
+	timeout, err := parseDuration(timeoutStr)
+	if err != nil || timeout <= 0 {
+		LogError("Invalid SHUTDOWN_TIMEOUT: %s, using default %s", timeoutStr, defaultShutdownTimeout)
+		return defaultShutdownTimeout
+	}
+	return timeout
+}
+
+// gracefulShutdown stops taking updates, lets the queued and in-flight ones finish
+// within the deadline of ctx, then closes the database and the log file. server is the
+// webhook server, or the metrics server in polling mode, where the poller's submits are
+// refused once the workers are closed. It may be nil.
+func gracefulShutdown(ctx context.Context, server *http.Server) {
+	if server != nil {
+		// Waits for webhook handlers still enqueueing updates
+		if err := server.Shutdown(ctx); err != nil {
+			LogError("Failed to shut down HTTP server: %v", err)
+		}
+	}
+
+	if err := workers.Close(ctx); err != nil {
+		LogError("Gave up waiting for %d queued updates: %v", workers.Depth(), err)
+	}
+
+	if err := waitWithContext(ctx, &backgroundTasks); err != nil {
+		LogError("Gave up waiting for background tasks: %v", err)
+	}
+
+	// Updates still queued weren't confirmed and are polled again on restart
+	if pollingOffsets != nil {
+		pollingOffsets.Flush()
+	}
+
+	if err := db.Close(); err != nil {
+		LogError("Failed to close database: %v", err)
+	}
+	LogInfo("Shutdown complete")
+	closeLogger()
+}
+
+// waitWithContext waits for wg, or returns the context's error once it is done
+func waitWithContext(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -2,10 +2,16 @@ package main
 
 import (
 	"fmt"
-	"log"
 	"os"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf16"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/lib/pq"
 )
 
 // Escape MarkdownV2 special chars for Telegram
@@ -33,12 +39,48 @@ func escapeMarkdownV2(text string) string {
 	return replacer.Replace(text)
 }
 
-func getMentions(chatID int64) string {
-	query := `SELECT user_id, first_name, last_name, username FROM members WHERE chat_id = $1`
-	rows, err := db.Query(query, chatID)
+// reachableMemberFilter excludes members who muted themselves or are in do-not-disturb
+const reachableMemberFilter = ` AND NOT m.muted AND (m.dnd_until IS NULL OR m.dnd_until <= NOW())`
+
+// getMentions builds mentions for every member of the chat.
+// Muted and do-not-disturb members are skipped unless urgent is set.
+func getMentions(chatID int64, urgent bool) []string {
+	query := `SELECT m.user_id, m.first_name, m.last_name, m.username FROM members m WHERE m.chat_id = $1`
+	if !urgent {
+		query += reachableMemberFilter
+	}
+	return queryMentions(query, chatID)
+}
+
+// getGroupMentions builds mentions for the members of one or more named groups
+func getGroupMentions(chatID int64, groupNames []string, urgent bool) []string {
+	query := `
+	SELECT DISTINCT m.user_id, m.first_name, m.last_name, m.username
+	FROM members m
+	JOIN mention_group_members g ON g.chat_id = m.chat_id AND g.user_id = m.user_id
+	WHERE m.chat_id = $1 AND g.group_name = ANY($2)`
+	if !urgent {
+		query += reachableMemberFilter
+	}
+	return queryMentions(query, chatID, pq.Array(groupNames))
+}
+
+// getNonVoterMentions builds mentions for the reachable members who haven't voted on a poll
+func getNonVoterMentions(chatID int64, pollID string) []string {
+	query := `
+	SELECT m.user_id, m.first_name, m.last_name, m.username
+	FROM members m
+	WHERE m.chat_id = $1
+		AND NOT EXISTS (SELECT 1 FROM poll_votes v WHERE v.poll_id = $2 AND v.user_id = m.user_id)` + reachableMemberFilter
+	return queryMentions(query, chatID, pollID)
+}
+
+// queryMentions runs a member query and formats every row as a MarkdownV2 mention
+func queryMentions(query string, args ...interface{}) []string {
+	rows, err := db.Query(query, args...)
 	if err != nil {
-		log.Println("Query failed:", err)
-		return ""
+		LogError("Query failed: %v", err)
+		return nil
 	}
 	defer rows.Close()
 
@@ -59,34 +101,189 @@ func getMentions(chatID int64) string {
 			mentions = append(mentions, fmt.Sprintf("[%s](tg://user?id=%d)", name, userID))
 		}
 	}
-	return strings.Join(mentions, " ")
+	return mentions
+}
+
+// chunkMentions splits mentions into message texts holding at most batchSize mentions
+// and maxLen UTF-16 code units each. The header goes at the top of the first chunk.
+func chunkMentions(header string, mentions []string, batchSize, maxLen int) []string {
+	var chunks []string
+	current := header
+	count := 0
+	for _, mention := range mentions {
+		sep := " "
+		if count == 0 {
+			sep = ""
+			if current != "" {
+				sep = "\n"
+			}
+		}
+		full := count >= batchSize || utf16Len(current+sep+mention) > maxLen
+		if current != "" && full {
+			chunks = append(chunks, current)
+			current, count, sep = "", 0, ""
+		}
+		current += sep + mention
+		count++
+	}
+	if current != "" {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
+func utf16Len(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
+
+// trimEntities shifts entities so they apply to a text with its first prefixLen UTF-16 code
+// units removed. Entities within the prefix are dropped and ones spanning its end are cut
+// to start where the remaining text does.
+func trimEntities(entities []tgbotapi.MessageEntity, prefixLen int) []tgbotapi.MessageEntity {
+	var trimmed []tgbotapi.MessageEntity
+	for _, entity := range entities {
+		end := entity.Offset + entity.Length
+		if end <= prefixLen {
+			continue
+		}
+		entity.Offset = max(entity.Offset-prefixLen, 0)
+		entity.Length = end - prefixLen - entity.Offset
+		trimmed = append(trimmed, entity)
+	}
+	return trimmed
+}
+
+// mediaKind names the type of media in a message, for logs and audit records
+func mediaKind(message *tgbotapi.Message) string {
+	switch {
+	case message.Photo != nil:
+		return "photo"
+	case message.Document != nil:
+		return "document"
+	case message.Video != nil:
+		return "video"
+	case message.Animation != nil:
+		return "animation"
+	case message.Audio != nil:
+		return "audio"
+	case message.Voice != nil:
+		return "voice"
+	default:
+		return "media"
+	}
+}
+
+// sendMentions posts text followed by the mentions, split across as many messages as needed.
+// Follow-up messages reply to the first one. Partial failures are reported in the chat in lang.
+func sendMentions(chatID int64, lang, text string, mentions []string) error {
+	membersMentionedTotal.Add(float64(len(mentions)))
+	if len(mentions) == 0 {
+		mentions = []string{escapeMarkdownV2(tr(lang, "mention.no_members"))}
+	}
+
+	chunks := chunkMentions(escapeMarkdownV2(text), mentions, mentionBatchSize, telegramMaxMessageLength)
+	firstMessageID := 0
+	failed := 0
+	for i, chunk := range chunks {
+		msg := tgbotapi.NewMessage(chatID, chunk)
+		msg.ParseMode = "MarkdownV2"
+		msg.ReplyToMessageID = firstMessageID
+		sent, err := bot.Send(msg)
+		mentionMessagesTotal.Inc(resultLabel(err))
+		if err != nil {
+			LogError("Failed to send mention message %d/%d to chat %d: %v", i+1, len(chunks), chatID, err)
+			failed++
+			continue
+		}
+		if firstMessageID == 0 {
+			firstMessageID = sent.MessageID
+		}
+	}
+
+	if failed > 0 {
+		sendText(chatID, tr(lang, "mention.partial", len(chunks)-failed, len(chunks)))
+		return fmt.Errorf("%d of %d mention messages failed", failed, len(chunks))
+	}
+	return nil
+}
+
+var mentionTagPattern = regexp.MustCompile(`@([A-Za-z0-9_]+)(!?)`)
+
+// detectMentionTags returns the lowercased @tags found in text, without duplicates.
+// urgent reports whether any tag was written with a trailing "!", e.g. @all!.
+func detectMentionTags(text string) (tags []string, urgent bool) {
+	for _, match := range mentionTagPattern.FindAllStringSubmatch(text, -1) {
+		tag := strings.ToLower(match[1])
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+		if match[2] == "!" {
+			urgent = true
+		}
+	}
+	return tags, urgent
+}
+
+// parseDuration parses durations like 30m, 8h or 2d, as used by /dnd and /cooldown
+func parseDuration(s string) (time.Duration, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// formatDuration renders a duration for chat replies, e.g. "2m30s" or "1h"
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d <= 0 {
+		return "0s"
+	}
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// displayName returns @username if set, otherwise the user's full name
+func displayName(user *tgbotapi.User) string {
+	if user.UserName != "" {
+		return "@" + user.UserName
+	}
+	name := user.FirstName
+	if user.LastName != "" {
+		name += " " + user.LastName
+	}
+	return name
 }
 
-func startText() string {
-	return "🌕 *Awooo! I am the Alpha Wolf of @werewolf_u2u_bot!*\n\n" +
-		"As the Alpha of this pack, I'll help gather all the wolves for our nightly hunts. Here's how to summon the pack:\n\n" +
-		"*Pack Commands:*\n" +
-		"• /start - Hear the Alpha's howl\n" +
-		"• /help - Learn the ways of the pack\n" +
-		"• /all - Summon all wolves to the hunt\n\n" +
-		"*Pack Features:*\n" +
-		"• Type `@all` in any message to call the pack\n" +
-		"• I track all wolves in our territory\n" +
-		"• Wolves who leave are removed from the pack\n\n" +
-		"*Note:* To summon the pack, I need to be an Alpha in the group. Grant me the necessary permissions to lead the hunt.\n\n"
+var groupNamePattern = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+// normalizeGroupName lowercases a group name and strips a leading @.
+// It returns "" if the name is invalid or reserved.
+func normalizeGroupName(name string) string {
+	name = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "@"))
+	if !groupNamePattern.MatchString(name) || name == "all" {
+		return ""
+	}
+	return name
 }
 
-func helpText() string {
-	return "🌕 *Pack Commands Guide*\n\n" +
-		"*How to Summon the Pack:*\n" +
-		"• Use /all to call all wolves to the hunt\n" +
-		"• Type @all in any message to gather the pack\n\n" +
-		"*Alpha's Notes:*\n" +
-		"• I track all wolves in our territory\n" +
-		"• Wolves who leave are removed from the pack\n" +
-		"• I need to be an Alpha to summon the pack\n\n" +
-		"*Need Help?*\n" +
-		"Use /start to hear the Alpha's howl again.\n\n"
+// sendText sends a plain text message, escaped for MarkdownV2
+func sendText(chatID int64, text string) {
+	msg := tgbotapi.NewMessage(chatID, escapeMarkdownV2(text))
+	msg.ParseMode = "MarkdownV2"
+	if _, err := bot.Send(msg); err != nil {
+		LogError("Failed to send message to chat %d: %v", chatID, err)
+	}
 }
 
 // Detect @sendto <chat_id> <message> pattern & return the chat_id and message
@@ -97,7 +294,7 @@ func detectSendToMessage(text string) (int64, string) {
 			chatIDStr := parts[1]
 			chatID, err := strconv.ParseInt(chatIDStr, 10, 64)
 			if err != nil {
-				log.Printf("Invalid chatID: %s, error: %v", chatIDStr, err)
+				LogWarn("Invalid chatID: %s, error: %v", chatIDStr, err)
 				return 0, ""
 			}
 
@@ -109,6 +306,23 @@ func detectSendToMessage(text string) (int64, string) {
 	return 0, ""
 }
 
+// initMentionBatchSize reads MENTION_BATCH_SIZE, the number of mentions sent per message
+func initMentionBatchSize() {
+	batchSizeStr := os.Getenv("MENTION_BATCH_SIZE")
+	if batchSizeStr == "" {
+		return
+	}
+
+	batchSize, err := strconv.Atoi(batchSizeStr)
+	if err != nil || batchSize <= 0 {
+		LogError("Invalid MENTION_BATCH_SIZE: %s, using default %d", batchSizeStr, mentionBatchSize)
+		return
+	}
+
+	mentionBatchSize = batchSize
+	LogInfo("Mention batch size set to %d", mentionBatchSize)
+}
+
 // initSpecialChatIDs initializes the special chat IDs from environment variable
 func initSpecialChatIDs() {
 	specialChatIDsStr := os.Getenv("SPECIAL_CHAT_IDS")
@@ -119,6 +333,7 @@ func initSpecialChatIDs() {
 
 	chatIDStrings := strings.Split(specialChatIDsStr, ",")
 	specialChatIDs = make([]int64, 0, len(chatIDStrings))
+	specialChatModes = make(map[int64]string, len(chatIDStrings))
 
 	for _, chatIDStr := range chatIDStrings {
 		chatIDStr = strings.TrimSpace(chatIDStr)
@@ -126,6 +341,16 @@ func initSpecialChatIDs() {
 			continue
 		}
 
+		// Each entry may carry a relay mode suffix, e.g. -100123:forward
+		mode := specialChatModeCopy
+		if idStr, modeStr, found := strings.Cut(chatIDStr, ":"); found {
+			chatIDStr, mode = idStr, strings.ToLower(strings.TrimSpace(modeStr))
+			if mode != specialChatModeCopy && mode != specialChatModeForward {
+				LogError("Invalid relay mode in SPECIAL_CHAT_IDS: %s, using %s", modeStr, specialChatModeCopy)
+				mode = specialChatModeCopy
+			}
+		}
+
 		var chatID int64
 		if _, err := fmt.Sscanf(chatIDStr, "%d", &chatID); err != nil {
 			LogError("Invalid chat ID format in SPECIAL_CHAT_IDS: %s", chatIDStr)
@@ -133,7 +358,37 @@ func initSpecialChatIDs() {
 		}
 
 		specialChatIDs = append(specialChatIDs, chatID)
+		specialChatModes[chatID] = mode
 	}
 
 	LogInfo("Initialized %d special chat IDs", len(specialChatIDs))
 }
+
+// initRelayOperatorIDs initializes the users allowed to use @sendto from environment variable
+func initRelayOperatorIDs() {
+	operatorIDsStr := os.Getenv("RELAY_OPERATOR_IDS")
+	if operatorIDsStr == "" {
+		LogInfo("RELAY_OPERATOR_IDS environment variable is not set, @sendto is disabled")
+		return
+	}
+
+	userIDStrings := strings.Split(operatorIDsStr, ",")
+	relayOperatorIDs = make([]int64, 0, len(userIDStrings))
+
+	for _, userIDStr := range userIDStrings {
+		userIDStr = strings.TrimSpace(userIDStr)
+		if userIDStr == "" {
+			continue
+		}
+
+		userID, err := strconv.ParseInt(userIDStr, 10, 64)
+		if err != nil {
+			LogError("Invalid user ID format in RELAY_OPERATOR_IDS: %s", userIDStr)
+			continue
+		}
+
+		relayOperatorIDs = append(relayOperatorIDs, userID)
+	}
+
+	LogInfo("Initialized %d relay operator IDs", len(relayOperatorIDs))
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestChunkMentions(t *testing.T) {
+	mentions := []string{"[a](tg://user?id=1)", "[b](tg://user?id=2)", "[c](tg://user?id=3)"}
+	tests := []struct {
+		name      string
+		header    string
+		mentions  []string
+		batchSize int
+		maxLen    int
+		want      []string
+	}{
+		{
+			name:      "fits in one message",
+			header:    "hi",
+			mentions:  mentions,
+			batchSize: 30,
+			maxLen:    4096,
+			want:      []string{"hi\n" + strings.Join(mentions, " ")},
+		},
+		{
+			name:      "batch limit",
+			header:    "hi",
+			mentions:  mentions,
+			batchSize: 2,
+			maxLen:    4096,
+			want:      []string{"hi\n" + mentions[0] + " " + mentions[1], mentions[2]},
+		},
+		{
+			name:      "length limit",
+			header:    "hi",
+			mentions:  mentions,
+			batchSize: 30,
+			maxLen:    len("hi\n") + len(mentions[0]) + 1 + len(mentions[1]),
+			want:      []string{"hi\n" + mentions[0] + " " + mentions[1], mentions[2]},
+		},
+		{
+			// Emoji take two UTF-16 code units, so this doesn't fit even though it has fewer runes
+			name:      "length counted in UTF-16",
+			header:    "😀😀",
+			mentions:  []string{"[😀](tg://user?id=1)"},
+			batchSize: 30,
+			maxLen:    utf16Len("😀😀\n[😀](tg://user?id=1)") - 1,
+			want:      []string{"😀😀", "[😀](tg://user?id=1)"},
+		},
+		{
+			name:      "header already too long",
+			header:    strings.Repeat("x", 50),
+			mentions:  mentions[:2],
+			batchSize: 30,
+			maxLen:    40,
+			want:      []string{strings.Repeat("x", 50), mentions[0] + " " + mentions[1]},
+		},
+		{
+			name:      "empty header",
+			header:    "",
+			mentions:  mentions,
+			batchSize: 2,
+			maxLen:    4096,
+			want:      []string{mentions[0] + " " + mentions[1], mentions[2]},
+		},
+		{
+			name:      "no mentions",
+			header:    "hi",
+			mentions:  nil,
+			batchSize: 30,
+			maxLen:    4096,
+			want:      []string{"hi"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := chunkMentions(tt.header, tt.mentions, tt.batchSize, tt.maxLen)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("chunkMentions() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrimEntities(t *testing.T) {
+	bold := func(offset, length int) tgbotapi.MessageEntity {
+		return tgbotapi.MessageEntity{Type: "bold", Offset: offset, Length: length}
+	}
+	tests := []struct {
+		name     string
+		prefix   string
+		entities []tgbotapi.MessageEntity
+		want     []tgbotapi.MessageEntity
+	}{
+		{
+			name:     "after the prefix",
+			prefix:   "@sendto 1 ",
+			entities: []tgbotapi.MessageEntity{bold(10, 5)},
+			want:     []tgbotapi.MessageEntity{bold(0, 5)},
+		},
+		{
+			name:     "inside the prefix",
+			prefix:   "@sendto 1 ",
+			entities: []tgbotapi.MessageEntity{{Type: "mention", Offset: 0, Length: 7}, bold(8, 2)},
+			want:     nil,
+		},
+		{
+			name:     "spanning the end of the prefix",
+			prefix:   "@sendto 1 ",
+			entities: []tgbotapi.MessageEntity{bold(8, 7)},
+			want:     []tgbotapi.MessageEntity{bold(0, 5)},
+		},
+		{
+			// 😀 takes two UTF-16 code units, so the prefix is 3 long
+			name:     "emoji in the prefix",
+			prefix:   "😀 ",
+			entities: []tgbotapi.MessageEntity{bold(0, 2), bold(3, 4)},
+			want:     []tgbotapi.MessageEntity{bold(0, 4)},
+		},
+		{
+			name:     "emoji after the prefix",
+			prefix:   "@sendto 1 ",
+			entities: []tgbotapi.MessageEntity{bold(10, 2), bold(13, 3)},
+			want:     []tgbotapi.MessageEntity{bold(0, 2), bold(3, 3)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := trimEntities(tt.entities, utf16Len(tt.prefix))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("trimEntities() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,14 +1,59 @@
 package main
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
+// secretTokenHeader carries the secret_token registered with setWebhook on every delivery
+const secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// webhookSecretStateKey is the bot_state key of the generated secret token
+const webhookSecretStateKey = "webhook_secret_token"
+
+// secretTokenPattern is the format Telegram accepts for secret_token
+var secretTokenPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+var (
+	// webhookSecretToken is set by initWebhookSecretToken before the server starts
+	webhookSecretToken string
+	// rejectedWebhookRequests counts deliveries refused for a missing or wrong secret token
+	rejectedWebhookRequests atomic.Int64
+)
+
+// initWebhookSecretToken reads WEBHOOK_SECRET_TOKEN, or generates a token and persists it
+// so that restarts and other replicas register and expect the same one
+func initWebhookSecretToken() {
+	if token := os.Getenv("WEBHOOK_SECRET_TOKEN"); token != "" {
+		if !secretTokenPattern.MatchString(token) {
+			LogFatal("WEBHOOK_SECRET_TOKEN must be 1-256 characters of A-Z, a-z, 0-9, _ and -")
+		}
+		webhookSecretToken = token
+		return
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		LogFatal("Failed to generate webhook secret token: %v", err)
+	}
+	token, err := loadOrStoreBotState(webhookSecretStateKey, hex.EncodeToString(buf))
+	if err != nil {
+		LogFatal("Failed to load webhook secret token: %v", err)
+	}
+	webhookSecretToken = token
+	LogInfo("Using the webhook secret token stored in the database")
+}
+
 // WebhookHandler handles incoming webhook requests from Telegram
 func webhookHandler(w http.ResponseWriter, r *http.Request) {
 	// Only accept POST requests
@@ -18,6 +63,14 @@ func webhookHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Only Telegram knows the secret token, anything else may be a forged update
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get(secretTokenHeader)), []byte(webhookSecretToken)) != 1 {
+		rejected := rejectedWebhookRequests.Add(1)
+		LogError("Rejected webhook request from %s with a missing or invalid secret token (%d rejected so far)", r.RemoteAddr, rejected)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
 	// Parse the update from the request body
 	var update tgbotapi.Update
 	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
@@ -26,42 +79,131 @@ func webhookHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Process the update
-	processUpdate(update)
+	// Acknowledge redeliveries of updates that are already queued or handled
+	if !seenWebhookUpdates.Add(update.UpdateID) {
+		newUpdateLog(update).Info("Ignoring duplicate update")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// Hand the update to the workers and acknowledge it right away, so a slow chat
+	// doesn't make Telegram time out and redeliver. If the queue stays full, refuse
+	// the update and let Telegram retry it later.
+	if !workers.TrySubmit(update, webhookEnqueueTimeout) {
+		newUpdateLog(update).Warn("Update queue is full, refusing update")
+		seenWebhookUpdates.Remove(update.UpdateID)
+		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+		return
+	}
 
 	// Respond with 200 OK
 	w.WriteHeader(http.StatusOK)
 }
 
-// processUpdate handles a single update (shared between polling and webhook)
-func processUpdate(update tgbotapi.Update) {
-	if update.Message != nil {
-		chatID := update.Message.Chat.ID
+// allowedUpdates lists the update kinds the bot subscribes to in both polling and webhook mode.
+// chat_member must be requested explicitly, Telegram doesn't send it by default.
+var allowedUpdates = []string{
+	"message",
+	"edited_message",
+	"chat_member",
+	"my_chat_member",
+	"poll_answer",
+	"callback_query",
+	"inline_query",
+}
 
-		// Save user to DB on any message
-		if update.Message.From != nil {
-			saveUser(chatID, update.Message.From)
-		}
+// processUpdate dispatches a single update by kind (shared between polling and webhook).
+// It runs on the worker responsible for the update's chat.
+func processUpdate(update tgbotapi.Update, ulog updateLog) {
+	updatesTotal.Inc(updateType(update))
+	switch {
+	case update.Message != nil:
+		handleMessage(update, ulog)
+	case update.EditedMessage != nil:
+		handleEditedMessage(update.EditedMessage)
+	case update.ChatMember != nil:
+		handleChatMemberUpdate(update.ChatMember, ulog)
+	case update.MyChatMember != nil:
+		handleMyChatMemberUpdate(update.MyChatMember, ulog)
+	case update.PollAnswer != nil:
+		handlePollAnswer(update.PollAnswer, ulog)
+	case update.CallbackQuery != nil:
+		handleCallbackQuery(update.CallbackQuery, ulog)
+	case update.InlineQuery != nil:
+		handleInlineQuery(update.InlineQuery, ulog)
+	default:
+		ulog.Info("Ignoring unsupported update")
+	}
+}
 
-		// Handle commands
-		if update.Message.IsCommand() {
-			handleCommands(update)
-			return
-		}
+// handleMessage handles a new message in any chat
+func handleMessage(update tgbotapi.Update, ulog updateLog) {
+	chatID := update.Message.Chat.ID
 
-		// Handle @all mentions
-		handleAtAllMention(update)
+	// Move the chat's data when a group is upgraded to a supergroup
+	if update.Message.MigrateToChatID != 0 || update.Message.MigrateFromChatID != 0 {
+		handleChatMigration(update.Message, ulog)
+		return
+	}
 
-		// Handle chat member updates
-		if update.ChatMember != nil {
-			handleChatMemberUpdate(update.ChatMember)
-		}
+	// Save user to DB on any message
+	if update.Message.From != nil {
+		saveUser(chatID, update.Message.From)
+	}
+
+	// Save users announced as joining the chat
+	if len(update.Message.NewChatMembers) > 0 {
+		handleNewChatMembers(update.Message)
+	}
 
-		// Handle send message to chat group
-		handleSendMessageToChatGroup(update)
+	// Route replies in special chats back to the original sender
+	if handleSpecialChatReply(update, ulog) {
+		return
+	}
 
-		// Handle forward message to special chat
-		handleForwardMessageToSpecialChat(update)
+	// Handle commands
+	if update.Message.IsCommand() {
+		handleCommands(update, ulog)
+		return
+	}
+
+	// Handle @all and named group mentions
+	handleMentionTags(update, ulog)
+
+	// Handle send message to chat group
+	handleSendMessageToChatGroup(update, ulog)
+
+	// Handle forward message to special chat
+	handleForwardMessageToSpecialChat(update, ulog)
+}
+
+// handleEditedMessage keeps the sender's details fresh; edits never re-trigger mentions or relays
+func handleEditedMessage(message *tgbotapi.Message) {
+	if message.From != nil {
+		saveUser(message.Chat.ID, message.From)
+	}
+}
+
+// handleCallbackQuery routes inline keyboard presses. Unknown ones are still acknowledged
+// so clients stop showing a spinner.
+func handleCallbackQuery(query *tgbotapi.CallbackQuery, ulog updateLog) {
+	switch {
+	case strings.HasPrefix(query.Data, settingsCallbackPrefix):
+		handleSettingsCallback(query, ulog)
+	default:
+		answerCallback(query.ID, "", ulog)
+	}
+}
+
+// handleInlineQuery answers inline queries with no results; the bot has no inline mode features
+func handleInlineQuery(query *tgbotapi.InlineQuery, ulog updateLog) {
+	answer := tgbotapi.InlineConfig{
+		InlineQueryID: query.ID,
+		Results:       []interface{}{},
+		CacheTime:     300,
+	}
+	if _, err := bot.Request(answer); err != nil {
+		ulog.Error("Failed to answer inline query %s: %v", query.ID, err)
 	}
 }
 
@@ -69,13 +211,15 @@ func processUpdate(update tgbotapi.Update) {
 func setupWebhook(webhookURL string) error {
 	LogInfo("Setting up webhook at: %s", webhookURL)
 
-	webhook, err := tgbotapi.NewWebhook(webhookURL)
-	if err != nil {
+	// WebhookConfig of this library version has no secret_token, so the request is built by hand
+	params := make(tgbotapi.Params)
+	params["url"] = webhookURL
+	params["secret_token"] = webhookSecretToken
+	if err := params.AddInterface("allowed_updates", allowedUpdates); err != nil {
 		return fmt.Errorf("failed to create webhook: %w", err)
 	}
 
-	_, err = bot.Request(webhook)
-	if err != nil {
+	if _, err := bot.MakeRequest("setWebhook", params); err != nil {
 		return fmt.Errorf("failed to set webhook: %w", err)
 	}
 
@@ -96,8 +240,9 @@ func removeWebhook() error {
 	return nil
 }
 
-// startWebhookServer starts the HTTP server for webhook handling
-func startWebhookServer() {
+// startWebhookServer starts the HTTP server for webhook handling in the background and
+// returns it, so it can be shut down gracefully
+func startWebhookServer() *http.Server {
 	webhookURL := os.Getenv("WEBHOOK_URL")
 	if webhookURL == "" {
 		LogFatal("WEBHOOK_URL environment variable is required for webhook mode")
@@ -109,23 +254,30 @@ func startWebhookServer() {
 	}
 
 	// Set up the webhook with Telegram
+	initWebhookSecretToken()
 	if err := setupWebhook(webhookURL); err != nil {
 		LogFatal("Failed to setup webhook: %v", err)
 	}
 
 	// Set up HTTP server
-	http.HandleFunc("/webhook", webhookHandler)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/webhook", webhookHandler)
+	registerStatusHandlers(mux, true)
 
-	// Health check endpoint
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+	// Plain health check endpoint, kept for existing setups; see /healthz and /readyz
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	})
 
 	LogInfo("Starting webhook server on port %s", port)
-	LogInfo("Webhook endpoint: /webhook")
+	LogInfo("Webhook endpoint: /webhook, status endpoints: /healthz, /readyz, /metrics")
 
-	if err := http.ListenAndServe(":"+port, nil); err != nil {
-		LogFatal("Failed to start webhook server: %v", err)
-	}
+	server := &http.Server{Addr: ":" + port, Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			LogFatal("Failed to start webhook server: %v", err)
+		}
+	}()
+	return server
 }
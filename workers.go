@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Defaults for the update worker pool, overridable with WORKER_COUNT and WORKER_QUEUE_SIZE
+const (
+	defaultWorkerCount     = 8
+	defaultWorkerQueueSize = 100
+)
+
+// webhookEnqueueTimeout is how long a webhook delivery waits for room in a full queue
+// before it is refused, so Telegram redelivers it later instead of timing out
+const webhookEnqueueTimeout = 5 * time.Second
+
+// workerPool processes updates concurrently. Each worker owns a queue and updates are
+// sharded by chat ID, so updates of one chat are handled in order while a slow chat
+// only holds up the chats that share its worker.
+type workerPool struct {
+	queues []chan tgbotapi.Update
+	wg     sync.WaitGroup
+
+	// mu is held for reading while submitting, so Close can't close a queue mid-send.
+	// closing wakes up submitters blocked on a full queue so Close doesn't wait for them.
+	mu      sync.RWMutex
+	closed  bool
+	closing chan struct{}
+}
+
+func newWorkerPool(workers, queueSize int) *workerPool {
+	pool := &workerPool{queues: make([]chan tgbotapi.Update, workers), closing: make(chan struct{})}
+	for i := range pool.queues {
+		pool.queues[i] = make(chan tgbotapi.Update, queueSize)
+		pool.wg.Add(1)
+		go pool.run(pool.queues[i])
+	}
+	return pool
+}
+
+func (p *workerPool) run(queue chan tgbotapi.Update) {
+	defer p.wg.Done()
+	for update := range queue {
+		processUpdateSafely(update)
+	}
+}
+
+// queueFor returns the queue of the worker responsible for the update's chat
+func (p *workerPool) queueFor(update tgbotapi.Update) chan tgbotapi.Update {
+	return p.queues[uint64(updateShardKey(update))%uint64(len(p.queues))]
+}
+
+// Submit queues an update, blocking while the worker's queue is full. It reports false if
+// ctx is done or the pool is closed before the update could be queued.
+func (p *workerPool) Submit(ctx context.Context, update tgbotapi.Update) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return false
+	}
+
+	select {
+	case p.queueFor(update) <- update:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-p.closing:
+		return false
+	}
+}
+
+// TrySubmit queues an update, waiting at most timeout for room. It reports whether the
+// update was queued.
+func (p *workerPool) TrySubmit(update tgbotapi.Update, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return p.Submit(ctx, update)
+}
+
+// Close stops accepting updates and waits until the queued ones are processed, or until
+// ctx is done. Submitting after Close is refused.
+func (p *workerPool) Close(ctx context.Context) error {
+	close(p.closing)
+	p.mu.Lock()
+	p.closed = true
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.mu.Unlock()
+	return waitWithContext(ctx, &p.wg)
+}
+
+// QueueSize returns how many updates each worker can queue
+func (p *workerPool) QueueSize() int {
+	return cap(p.queues[0])
+}
+
+// Depth returns the number of updates waiting in all queues
+func (p *workerPool) Depth() int {
+	depth := 0
+	for _, queue := range p.queues {
+		depth += len(queue)
+	}
+	return depth
+}
+
+// updateShardKey returns the chat an update belongs to. Updates without a chat are keyed
+// by their sender, which keeps e.g. one user's poll answers in order.
+func updateShardKey(update tgbotapi.Update) int64 {
+	switch {
+	case update.Message != nil:
+		return update.Message.Chat.ID
+	case update.EditedMessage != nil:
+		return update.EditedMessage.Chat.ID
+	case update.ChatMember != nil:
+		return update.ChatMember.Chat.ID
+	case update.MyChatMember != nil:
+		return update.MyChatMember.Chat.ID
+	case update.CallbackQuery != nil && update.CallbackQuery.Message != nil:
+		return update.CallbackQuery.Message.Chat.ID
+	case update.CallbackQuery != nil:
+		return update.CallbackQuery.From.ID
+	case update.PollAnswer != nil:
+		return update.PollAnswer.User.ID
+	case update.InlineQuery != nil:
+		return update.InlineQuery.From.ID
+	default:
+		return 0
+	}
+}
+
+// processUpdateSafely processes an update, recovering from panics so one bad update
+// doesn't take its worker down
+func processUpdateSafely(update tgbotapi.Update) {
+	ulog := newUpdateLog(update)
+	start := time.Now()
+	defer func() {
+		// A panicking update counts as processed too, or polling would never move past it
+		if pollingOffsets != nil {
+			pollingOffsets.Done(update.UpdateID)
+		}
+		if r := recover(); r != nil {
+			ulog.Error("Panic while processing update: %v\n%s", r, debug.Stack())
+			return
+		}
+		ulog.Debug("Processed %s update in %s", updateType(update), time.Since(start))
+	}()
+	processUpdate(update, ulog)
+}
+
+// startWorkerPool starts the update workers, sized from WORKER_COUNT and WORKER_QUEUE_SIZE
+func startWorkerPool() {
+	workerCount := envPositiveInt("WORKER_COUNT", defaultWorkerCount)
+	queueSize := envPositiveInt("WORKER_QUEUE_SIZE", defaultWorkerQueueSize)
+	workers = newWorkerPool(workerCount, queueSize)
+	LogInfo("Started %d update workers with %d queued updates each", workerCount, queueSize)
+}
+
+// envPositiveInt reads a positive integer from the environment, or returns the default
+func envPositiveInt(name string, defaultValue int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		LogError("Invalid %s: %s, using default %d", name, value, defaultValue)
+		return defaultValue
+	}
+	return n
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestWorkerPoolRefusesSubmitsAfterClose(t *testing.T) {
+	pool := newWorkerPool(2, 1)
+
+	// Submitters racing Close must be refused, not panic with a send on a closed channel
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			pool.TrySubmit(tgbotapi.Update{UpdateID: id}, time.Second)
+		}(i)
+	}
+	if err := pool.Close(context.Background()); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	wg.Wait()
+
+	if pool.Submit(context.Background(), tgbotapi.Update{UpdateID: 100}) {
+		t.Error("Submit() after Close = true, want false")
+	}
+}